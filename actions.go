@@ -0,0 +1,242 @@
+package autorefresh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+)
+
+// Action is a development chore, such as reseeding the database, registered with RegisterAction and
+// invoked from the actions menu of the connected pages.
+type Action struct {
+	Name        string
+	Description string
+	// Reload reloads the connected browsers after the action succeeded.
+	Reload bool
+
+	run     func(ctx context.Context) error
+	running bool
+}
+
+// actionState describes an action to the browsers.
+type actionState struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Running     bool   `json:"running"`
+}
+
+// ActionResult is sent to the browsers when an action finished.
+type ActionResult struct {
+	Name     string        `json:"name"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ActionProgress is sent to the browsers when a running action reports its progress.
+type ActionProgress struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+type actionKey struct{}
+
+type runningAction struct {
+	reloader *PageReloader
+	name     string
+}
+
+// RegisterAction adds an action to the actions menu of the connected pages, replacing a previously
+// registered action with the same name. Actions run in the background, outliving the page they were
+// invoked from, and only one invocation of an action runs at a time. The returned Action can be
+// configured before serving the PageReloader.
+func (p *PageReloader) RegisterAction(name string, run func(ctx context.Context) error) *Action {
+	action := &Action{Name: name, run: run}
+	p.mu.Lock()
+	first := p.actions == nil
+	if first {
+		p.actions = map[string]*Action{}
+	}
+	p.actions[name] = action
+	p.mu.Unlock()
+	if first {
+		p.addScript(actionsScript)
+		p.OnMessage("action", p.invokeAction)
+	}
+	p.publishActions()
+	return action
+}
+
+// ReportProgress shows message as the progress of the action ctx belongs to in the actions menu. It
+// does nothing outside of an action.
+func ReportProgress(ctx context.Context, format string, args ...any) {
+	if a, ok := ctx.Value(actionKey{}).(runningAction); ok {
+		a.reloader.Broadcast(Message{Type: "action-progress", Data: ActionProgress{Name: a.name, Message: fmt.Sprintf(format, args...)}})
+	}
+}
+
+// publishActions sends the registered actions to the browsers, including browsers connecting later.
+func (p *PageReloader) publishActions() {
+	p.retain("actions", Message{Type: "actions", Data: actionList{p}})
+}
+
+// actionList is the list of registered actions. It is built as it is sent to a browser, so the
+// configuration of an Action after RegisterAction returned is included.
+type actionList struct {
+	p *PageReloader
+}
+
+func (l actionList) MarshalJSON() ([]byte, error) {
+	l.p.mu.Lock()
+	actions := make([]actionState, 0, len(l.p.actions))
+	for _, a := range l.p.actions {
+		actions = append(actions, actionState{Name: a.Name, Description: a.Description, Running: a.running})
+	}
+	l.p.mu.Unlock()
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Name < actions[j].Name })
+	return json.Marshal(actions)
+}
+
+// invokeAction runs the action named in a message of a browser that knows the token.
+func (p *PageReloader) invokeAction(_ context.Context, from *Client, m Message) {
+	var invocation struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+	}
+	if err := m.Decode(&invocation); err != nil || !p.VerifyToken(invocation.Token) {
+		from.Send(Message{Type: "action-done", Data: ActionResult{Name: invocation.Name, Error: "not authorized"}})
+		return
+	}
+	p.mu.Lock()
+	action, ok := p.actions[invocation.Name]
+	running := ok && action.running
+	if ok && !running {
+		action.running = true
+	}
+	p.mu.Unlock()
+	switch {
+	case !ok:
+		from.Send(Message{Type: "action-done", Data: ActionResult{Name: invocation.Name, Error: "unknown action"}})
+		return
+	case running:
+		from.Send(Message{Type: "action-done", Data: ActionResult{Name: invocation.Name, Error: "already running"}})
+		return
+	}
+	p.publishActions()
+
+	go func() {
+		start := time.Now()
+		ctx := context.WithValue(context.Background(), actionKey{}, runningAction{reloader: p, name: action.Name})
+		err := runAction(ctx, action.run)
+		result := ActionResult{Name: action.Name, Duration: time.Since(start)}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		p.mu.Lock()
+		action.running = false
+		reload := action.Reload
+		p.mu.Unlock()
+		p.publishActions()
+		p.Broadcast(Message{Type: "action-done", Data: result})
+		if reload && err == nil {
+			p.Reload()
+		}
+	}()
+}
+
+// runAction runs an action, turning a panic into an error so it is shown in the browser.
+func runAction(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// actionsScript renders the actions menu in the top left corner. The progress and result of the
+// last invocation of each action are shown next to it.
+const actionsScript template.JS = `
+		(function () {
+			let actions = [];
+			const status = {};
+			let open = false;
+			let root = null;
+
+			function render() {
+				if (!document.body) {
+					return;
+				}
+				if (root === null) {
+					root = document.createElement("div");
+					root.id = "autorefresh-actions";
+					root.style.cssText = "position:fixed;left:8px;top:8px;z-index:2147483646;font:12px/1.4 monospace;color:#eee;";
+					document.body.appendChild(root);
+				}
+				root.replaceChildren();
+				const toggle = document.createElement("button");
+				toggle.textContent = "actions";
+				toggle.style.cssText = "font:inherit;opacity:0.8;";
+				toggle.onclick = function () {
+					open = !open;
+					render();
+				};
+				root.appendChild(toggle);
+				if (!open) {
+					return;
+				}
+				const menu = document.createElement("div");
+				menu.style.cssText = "background:#222;padding:8px;margin-top:4px;border-radius:4px;max-width:min(480px,90vw);";
+				if (actions.length === 0) {
+					menu.textContent = "No actions registered.";
+				}
+				actions.forEach(function (action) {
+					const row = document.createElement("div");
+					row.style.cssText = "margin:4px 0;";
+					const button = document.createElement("button");
+					button.textContent = action.name + (action.running ? " (running)" : "");
+					button.title = action.description || "";
+					button.disabled = action.running;
+					button.style.cssText = "font:inherit;";
+					button.onclick = function () {
+						status[action.name] = { message: "starting" };
+						autorefresh.send("action", { name: action.name, token: config.token });
+						render();
+					};
+					row.appendChild(button);
+					const s = status[action.name];
+					if (s) {
+						const text = document.createElement("span");
+						text.textContent = " " + s.message;
+						text.style.color = s.error ? "#ff8080" : s.done ? "#80ff80" : "#eee";
+						row.appendChild(text);
+					}
+					menu.appendChild(row);
+				});
+				root.appendChild(menu);
+			}
+
+			autorefresh.on("actions", function (list) {
+				actions = list;
+				render();
+			});
+			autorefresh.on("action-progress", function (progress) {
+				status[progress.name] = { message: progress.message };
+				render();
+			});
+			autorefresh.on("action-done", function (result) {
+				const duration = (result.duration / 1e6).toFixed(0) + "ms";
+				status[result.name] = result.error ?
+					{ message: "failed after " + duration + ": " + result.error, error: true } :
+					{ message: "done in " + duration, done: true };
+				render();
+			});
+			if (document.body) {
+				render();
+			} else {
+				document.addEventListener("DOMContentLoaded", render, { once: true });
+			}
+		})();
+`
@@ -0,0 +1,93 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestActions(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	reloader.RegisterAction("reseed", func(ctx context.Context) error {
+		autorefresh.ReportProgress(ctx, "inserted %d users", 3)
+		return nil
+	}).Reload = true
+	reloader.RegisterAction("clear-cache", func(context.Context) error {
+		return errors.New("cache is locked")
+	}).Description = "Empty the page cache"
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	token := regexp.MustCompile(`"token":"(\w+)"`).FindStringSubmatch(script.String())
+	if token == nil {
+		t.Fatalf("Script does not include the token. Rendered %s", script.String())
+	}
+
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	// next returns the next message of type typ, skipping the others.
+	next := func(typ string) string {
+		t.Helper()
+		for {
+			var m struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := wsjson.Read(ctx, socket, &m); err != nil {
+				t.Fatalf("Did not receive %s. %v", typ, err)
+			}
+			if m.Type == typ {
+				return string(m.Data)
+			}
+		}
+	}
+	if actions := next("actions"); !strings.Contains(actions, `"name":"clear-cache","description":"Empty the page cache"`) || !strings.Contains(actions, `"name":"reseed"`) {
+		t.Fatalf("Unexpected actions %s", actions)
+	}
+
+	invoke := func(name, token string) {
+		t.Helper()
+		data := map[string]string{"name": name, "token": token}
+		if err := wsjson.Write(ctx, socket, autorefresh.Message{Type: "action", Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	invoke("reseed", "guess")
+	if result := next("action-done"); !strings.Contains(result, "not authorized") {
+		t.Fatalf("Action was invoked without the token: %s", result)
+	}
+	invoke("reseed", token[1])
+	if progress := next("action-progress"); !strings.Contains(progress, "inserted 3 users") {
+		t.Fatalf("Unexpected progress %s", progress)
+	}
+	if result := next("action-done"); strings.Contains(result, "error") {
+		t.Fatalf("Action failed: %s", result)
+	}
+	next("reload")
+	invoke("clear-cache", token[1])
+	if result := next("action-done"); !strings.Contains(result, `"error":"cache is locked"`) {
+		t.Fatalf("Unexpected result %s", result)
+	}
+}
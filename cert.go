@@ -0,0 +1,201 @@
+package autorefresh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrCertificate = errors.New("Failed to set up certificates")
+
+const (
+	caCertFile = "ca.pem"
+	caKeyFile  = "ca-key.pem"
+	// leafValidity stays below the 398 days browsers accept for leaf certificates.
+	leafValidity = time.Hour * 24 * 397
+	caValidity   = time.Hour * 24 * 365 * 10
+)
+
+// DefaultCertDir returns the directory certificates are cached in when no other is given.
+func DefaultCertDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	return filepath.Join(dir, "autorefresh", "certs"), nil
+}
+
+// LoadOrCreateCA returns the local certificate authority cached in dir, creating it when there is
+// none yet. Browsers only trust certificates it signs after dir/ca.pem is added to their trust store.
+func LoadOrCreateCA(dir string) (tls.Certificate, error) {
+	certPath, keyPath := filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile)
+	if ca, err := loadCertificate(certPath, keyPath); err == nil && time.Now().Before(ca.Leaf.NotAfter) {
+		return ca, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{Organization: []string{"autorefresh development CA"}, CommonName: "autorefresh development CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	if err := writeCertificate(dir, certPath, keyPath, der, key); err != nil {
+		return tls.Certificate{}, err
+	}
+	return loadCertificate(certPath, keyPath)
+}
+
+// LeafCertificate returns a certificate for hosts signed by the local certificate authority in dir,
+// reusing the one cached for the same hosts while it is valid. Hosts can be names or IP addresses.
+func LeafCertificate(dir string, hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return tls.Certificate{}, fmt.Errorf("%w: no hosts given", ErrInvalidParameters)
+	}
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	sorted := append([]string(nil), hosts...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	name := "leaf-" + hex.EncodeToString(sum[:8])
+	certPath, keyPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	if leaf, err := loadCertificate(certPath, keyPath); err == nil &&
+		time.Now().Add(time.Hour*24).Before(leaf.Leaf.NotAfter) && leaf.Leaf.CheckSignatureFrom(ca.Leaf) == nil {
+		return leaf, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{Organization: []string{"autorefresh development certificate"}, CommonName: sorted[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Leaf, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	if err := writeCertificate(dir, certPath, keyPath, der, key); err != nil {
+		return tls.Certificate{}, err
+	}
+	return loadCertificate(certPath, keyPath)
+}
+
+// DevTLSConfig returns a TLS configuration serving a certificate for localhost, the loopback
+// addresses and hosts, signed by the local certificate authority in dir.
+func DevTLSConfig(dir string, hosts ...string) (*tls.Config, error) {
+	all := []string{"localhost", "127.0.0.1", "::1"}
+	for _, h := range hosts {
+		if h != "" && !contains(all, h) {
+			all = append(all, h)
+		}
+	}
+	leaf, err := LeafCertificate(dir, all...)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{leaf}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// ListenAndServeTLS serves handler over HTTPS on addr with a certificate from DevTLSConfig, so the
+// application and the PageReloader socket are served over https and wss during development.
+func ListenAndServeTLS(addr string, handler http.Handler, dir string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidParameters, err)
+	}
+	config, err := DevTLSConfig(dir, host)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: config}
+	return server.ListenAndServeTLS("", "")
+}
+
+func loadCertificate(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return cert, nil
+}
+
+func writeCertificate(dir, certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("%w: %w", ErrCertificate, err)
+	}
+	return nil
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package autorefresh_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestDevTLSConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	config, err := autorefresh.DevTLSConfig(dir)
+	if err != nil {
+		t.Fatalf("Could not create TLS config. %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "secure")
+	}))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatalf("CA was not written. %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Certificate was not trusted through the CA. %v", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != "secure" {
+		t.Fatalf("Unexpected body %q", b)
+	}
+
+	again, err := autorefresh.DevTLSConfig(dir)
+	if err != nil {
+		t.Fatalf("Could not load cached TLS config. %v", err)
+	}
+	if again.Certificates[0].Leaf.SerialNumber.Cmp(config.Certificates[0].Leaf.SerialNumber) != 0 {
+		t.Fatal("Cached certificate was not reused")
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runLAN(args []string) error {
+	fs := flag.NewFlagSet("lan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autorefresh lan [flags]\n\nPrints the URLs and a QR code to open a server of this machine from other devices on the network.")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("listen", ":8080", "address the server listens on")
+	secure := fs.Bool("tls", false, "the server is served over HTTPS")
+	_ = fs.Parse(args)
+	return printLANURLs(os.Stdout, *addr, *secure)
+}
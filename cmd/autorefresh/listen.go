@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+	"github.com/lavigneer/browser-autorefresh/internal/qr"
+)
+
+// listenFlags are the flags shared by the commands serving HTTP.
+type listenFlags struct {
+	addr  *string
+	tls   *bool
+	certs *string
+	lan   *bool
+}
+
+func addListenFlags(fs *flag.FlagSet) listenFlags {
+	return listenFlags{
+		addr:  fs.String("listen", ":8080", "address to listen on"),
+		tls:   fs.Bool("tls", false, "serve over HTTPS with a certificate signed by a local development CA"),
+		certs: fs.String("certs", "", "`dir` to cache the development CA and certificates in (default in the user cache dir)"),
+		lan:   fs.Bool("lan", false, "print the URLs and a QR code to open the server from other devices on the network"),
+	}
+}
+
+// server creates a server for handler, configured for HTTPS when requested. With -lan, the
+// reloader accepts connections from pages opened on the LAN addresses of this machine.
+func (f listenFlags) server(handler http.Handler, reloader *autorefresh.PageReloader) (*http.Server, error) {
+	server := &http.Server{Addr: *f.addr, Handler: handler}
+	var lanHosts []string
+	if *f.lan {
+		if err := reloader.AllowLAN(); err != nil {
+			return nil, err
+		}
+		ips, err := autorefresh.LANAddresses()
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			lanHosts = append(lanHosts, ip.String())
+		}
+	}
+	if !*f.tls {
+		return server, nil
+	}
+	dir := *f.certs
+	if dir == "" {
+		var err error
+		if dir, err = autorefresh.DefaultCertDir(); err != nil {
+			return nil, err
+		}
+	}
+	host, _, err := net.SplitHostPort(*f.addr)
+	if err != nil {
+		return nil, err
+	}
+	if server.TLSConfig, err = autorefresh.DevTLSConfig(dir, append(lanHosts, host)...); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "add %s to your browser's trusted certificates to avoid certificate warnings\n", filepath.Join(dir, "ca.pem"))
+	return server, nil
+}
+
+// url returns the URL the server is reachable at on this machine.
+func (f listenFlags) url() string {
+	scheme := "http"
+	if *f.tls {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(*f.addr)
+	if err != nil {
+		return scheme + "://" + *f.addr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// printLAN prints the URLs the server is reachable at from other devices on the network, with a QR
+// code of the first one to open it on a phone, when -lan is set.
+func (f listenFlags) printLAN() error {
+	if !*f.lan {
+		return nil
+	}
+	return printLANURLs(os.Stderr, *f.addr, *f.tls)
+}
+
+func printLANURLs(w io.Writer, addr string, secure bool) error {
+	urls, err := autorefresh.LANURLs(addr, secure)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		fmt.Fprintf(w, "%s is not reachable from the network, listen on all interfaces with -listen :port\n", addr)
+		return nil
+	}
+	fmt.Fprintln(w, "on your network:")
+	for _, u := range urls {
+		fmt.Fprintf(w, "  %s\n", u)
+	}
+	code, err := qr.Encode([]byte(urls[0]))
+	if err != nil {
+		return err
+	}
+	return code.WriteTerminal(w)
+}
+
+// serve runs server until ctx is cancelled.
+func serve(ctx context.Context, server *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			errs <- server.ListenAndServeTLS("", "")
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,59 @@
+// Command autorefresh provides development tools built on the autorefresh package.
+//
+// Usage:
+//
+//	autorefresh <command> [flags]
+//
+// Run "autorefresh <command> -h" for the flags of each command.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// defaultPath is the path the reloader socket is served at, matching the examples.
+const defaultPath = "/__dev/auto-refresh"
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"lan":      {"print the URLs and a QR code to open a server from other devices", runLAN},
+	"markdown": {"preview Markdown documents, reloading them on save", runMarkdown},
+	"procfile": {"run the processes of a Procfile behind the proxy", runProcfile},
+	"proxy":    {"reverse proxy that holds requests while the app restarts", runProxy},
+	"run":      {"run and supervise the app behind the proxy, showing crashes in the browser", runRun},
+	"serve":    {"serve a directory of static files with live reload", runServe},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "autorefresh %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: autorefresh <command> [flags]\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
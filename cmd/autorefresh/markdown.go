@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+	"github.com/lavigneer/browser-autorefresh/internal/markdown"
+)
+
+// defaultLayout is used to wrap rendered documents when no layout is given. Layouts are executed
+// with a markdownPage and can include the reload script with the "autorefresh" template.
+const defaultLayout = `<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1">
+		<title>{{ .Title }}</title>
+		<style>
+			body { max-width: 50rem; margin: 2rem auto; padding: 0 1rem; font: 16px/1.6 system-ui, sans-serif; color: #1f2328; }
+			pre { background: #f6f8fa; padding: 1rem; overflow: auto; }
+			code { font: 0.9em ui-monospace, monospace; }
+			:not(pre) > code { background: #eff1f3; padding: 0.1em 0.3em; border-radius: 4px; }
+			table { border-collapse: collapse; }
+			th, td { border: 1px solid #d1d9e0; padding: 0.3rem 0.8rem; }
+			blockquote { margin: 0; padding-left: 1rem; border-left: 4px solid #d1d9e0; color: #59636e; }
+			img { max-width: 100%; }
+		</style>
+		{{ template "autorefresh" . }}
+	</head>
+	<body>
+		{{ .Content }}
+	</body>
+</html>
+`
+
+// markdownPage is the data layouts are executed with.
+type markdownPage struct {
+	Title   string
+	Path    string
+	Content template.HTML
+}
+
+type markdownServer struct {
+	dir        string
+	layoutFile string
+	reloader   *autorefresh.PageReloader
+	// base holds the reloader's script as the "autorefresh" template, layouts are parsed into clones
+	// of it. It is never executed itself, so it can be cloned for every request.
+	base  *template.Template
+	files http.Handler
+}
+
+func runMarkdown(args []string) error {
+	fs := flag.NewFlagSet("markdown", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autorefresh markdown [flags] [dir]")
+		fs.PrintDefaults()
+	}
+	listen := addListenFlags(fs)
+	reloadPath := fs.String("path", defaultPath, "path to serve the reloader socket at")
+	refreshRate := fs.Uint("refresh-rate", 250, "reconnect interval of the browser script in milliseconds")
+	layout := fs.String("layout", "", "html/template `file` to wrap documents in, executed with .Title, .Path and .Content")
+	_ = fs.Parse(args)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	reloader, err := autorefresh.New(nil, *reloadPath, *refreshRate)
+	if err != nil {
+		return err
+	}
+	m, err := newMarkdownServer(dir, *layout, reloader)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle(reloader.Path+"/", reloader)
+	mux.Handle("/", m)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go autorefresh.NewWatcher(dir).Watch(ctx, func([]string) { reloader.Reload() })
+	if *layout != "" {
+		watcher := autorefresh.NewWatcher(filepath.Dir(*layout))
+		watcher.Match = autorefresh.MatchPatterns(filepath.Base(*layout))
+		go watcher.Watch(ctx, func([]string) { reloader.Reload() })
+	}
+
+	server, err := listen.server(mux, reloader)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "previewing %s on %s\n", dir, listen.url())
+	if err := listen.printLAN(); err != nil {
+		return err
+	}
+	err = serve(ctx, server)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// newMarkdownServer creates a markdownServer for dir whose pages connect to reloader, and checks
+// that the layout parses.
+func newMarkdownServer(dir, layoutFile string, reloader *autorefresh.PageReloader) (*markdownServer, error) {
+	reloader.PreserveScroll = true
+	base, err := reloader.Template.Clone()
+	if err != nil {
+		return nil, err
+	}
+	m := &markdownServer{dir: dir, layoutFile: layoutFile, reloader: reloader, base: base, files: http.FileServer(http.Dir(dir))}
+	if _, err := m.layout(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// layout parses the layout for every request, so changes to it show up on the next reload.
+func (m *markdownServer) layout() (*template.Template, error) {
+	src := defaultLayout
+	if m.layoutFile != "" {
+		b, err := os.ReadFile(m.layoutFile)
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	}
+	t, err := m.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.New("layout").Parse(src)
+}
+
+func (m *markdownServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	file := filepath.Join(m.dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	switch {
+	case err == nil && info.IsDir():
+		m.serveIndex(w, name, file)
+	case err == nil && strings.EqualFold(path.Ext(name), ".md"):
+		m.serveDocument(w, name, file)
+	default:
+		m.files.ServeHTTP(w, r)
+	}
+}
+
+func (m *markdownServer) serveDocument(w http.ResponseWriter, name, file string) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	title := markdown.Title(src)
+	if title == "" {
+		title = path.Base(name)
+	}
+	m.render(w, markdownPage{Title: title, Path: name, Content: template.HTML(markdown.Render(src))})
+}
+
+// serveIndex lists the Markdown documents and directories in a directory.
+func (m *markdownServer) serveIndex(w http.ResponseWriter, name, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var links []string
+	for _, e := range entries {
+		entry := e.Name()
+		if strings.HasPrefix(entry, ".") {
+			continue
+		}
+		if e.IsDir() {
+			entry += "/"
+		} else if !strings.EqualFold(path.Ext(entry), ".md") {
+			continue
+		}
+		links = append(links, entry)
+	}
+	sort.Strings(links)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ul>\n", template.HTMLEscapeString(name))
+	for _, l := range links {
+		href := path.Join(name, l)
+		if strings.HasSuffix(l, "/") {
+			href += "/"
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", template.HTMLEscapeString(href), template.HTMLEscapeString(l))
+	}
+	b.WriteString("</ul>\n")
+	m.render(w, markdownPage{Title: name, Path: name, Content: template.HTML(b.String())})
+}
+
+func (m *markdownServer) render(w http.ResponseWriter, page markdownPage) {
+	t, err := m.layout()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, page); err != nil {
+		fmt.Fprintf(os.Stderr, "rendering %s: %v\n", page.Path, err)
+	}
+}
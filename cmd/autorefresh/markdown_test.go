@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestMarkdownPagesShareTheReloaderScript(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	m, err := newMarkdownServer(dir, "", reloader)
+	if err != nil {
+		t.Fatalf("Could not create server. %v", err)
+	}
+	reloader.Sync = true
+
+	token := regexp.MustCompile(`"token":"([0-9a-f]+)"`)
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/README.md", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, "<title>Hello</title>") || !strings.Contains(body, `"preserveScroll":true`) || !strings.Contains(body, `autorefresh.send("sync"`) {
+			t.Fatalf("Page does not include the reloader's script. Got %s", body)
+		}
+		tokens = append(tokens, token.FindStringSubmatch(body)[1])
+	}
+	if tokens[0] != tokens[1] {
+		t.Fatalf("Pages got different tokens %v", tokens)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+type process struct {
+	name    string
+	command string
+}
+
+// parseProcfile parses "name: command" lines, skipping blank lines and # comments.
+func parseProcfile(r io.Reader) ([]process, error) {
+	var processes []process
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, command, ok := strings.Cut(line, ":")
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+		if !ok || name == "" || command == "" {
+			return nil, fmt.Errorf("line %d: expected \"name: command\"", n)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: duplicate process %q", n, name)
+		}
+		seen[name] = true
+		processes = append(processes, process{name: name, command: command})
+	}
+	return processes, scanner.Err()
+}
+
+// processOptions collects repeated name=value flags by process name.
+type processOptions map[string][]string
+
+func (o processOptions) String() string {
+	var options []string
+	for name, values := range o {
+		for _, value := range values {
+			options = append(options, name+"="+value)
+		}
+	}
+	sort.Strings(options)
+	return strings.Join(options, " ")
+}
+
+func (o processOptions) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || name == "" || value == "" {
+		return fmt.Errorf("expected name=value, got %q", v)
+	}
+	o[name] = append(o[name], value)
+	return nil
+}
+
+var processColors = []string{"36", "33", "32", "35", "34", "31"}
+
+// prefixWriter writes each line prefixed with the name of the process it came from.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+	onLine func(line string)
+}
+
+func (w *prefixWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, line)
+		if w.onLine != nil {
+			w.onLine(line)
+		}
+	}
+}
+
+func runProcfile(args []string) error {
+	fs := flag.NewFlagSet("procfile", flag.ExitOnError)
+	file := fs.String("f", "Procfile", "Procfile to run")
+	flags := addProxyFlags(fs)
+	debounce := fs.Duration("debounce", time.Millisecond*300, "reload requests within this duration result in a single reload")
+	watch := processOptions{}
+	fs.Var(watch, "watch", "restart a process when files matching a pattern change, as `name=pattern` (repeatable)")
+	reloadOn := processOptions{}
+	fs.Var(reloadOn, "reload-on", "reload browsers when a process prints a line matching a regexp, as `name=regexp` (repeatable)")
+	_ = fs.Parse(args)
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	processes, err := parseProcfile(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	if len(processes) == 0 {
+		return fmt.Errorf("%s: no processes", *file)
+	}
+	names := map[string]bool{}
+	width := 0
+	for _, p := range processes {
+		names[p.name] = true
+		if len(p.name) > width {
+			width = len(p.name)
+		}
+	}
+	for _, options := range []processOptions{watch, reloadOn} {
+		for name := range options {
+			if !names[name] {
+				return fmt.Errorf("unknown process %q", name)
+			}
+		}
+	}
+
+	patterns := map[string][]*regexp.Regexp{}
+	for name, exprs := range reloadOn {
+		for _, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("reload-on %s: %w", name, err)
+			}
+			patterns[name] = append(patterns[name], re)
+		}
+	}
+
+	proxy, err := flags.proxy()
+	if err != nil {
+		return err
+	}
+	reloader := proxy.Reloader
+	reloader.ReloadDebounce = *debounce
+	// While the application is down the proxy reloads browsers once it is back, so reloading any
+	// earlier would only result in a second reload.
+	reload := func() {
+		if proxy.Healthy() {
+			reloader.Reload()
+		}
+	}
+	server, err := flags.listen.server(proxy, proxy.Reloader)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "proxying %s to %s\n", flags.listen.url(), proxy.Upstream)
+	if err := flags.listen.printLAN(); err != nil {
+		return err
+	}
+
+	// Nothing fails from here on until serving, so every process started is stopped before returning.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go proxy.Monitor(ctx)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, p := range processes {
+		p := p
+		onLine := func(line string) {
+			for _, re := range patterns[p.name] {
+				if re.MatchString(line) {
+					reload()
+					return
+				}
+			}
+		}
+		prefix := fmt.Sprintf("\x1b[%sm%-*s |\x1b[0m ", processColors[i%len(processColors)], width, p.name)
+
+		supervisor := autorefresh.NewSupervisor(reloader, p.name)
+		supervisor.Command = func() *exec.Cmd { return exec.Command("sh", "-c", p.command) }
+		supervisor.Stdout = &prefixWriter{mu: &mu, out: os.Stdout, prefix: prefix, onLine: onLine}
+		supervisor.Stderr = &prefixWriter{mu: &mu, out: os.Stderr, prefix: prefix, onLine: onLine}
+
+		if len(watch[p.name]) > 0 {
+			watcher := autorefresh.NewWatcher(".")
+			watcher.Match = autorefresh.MatchPatterns(watch[p.name]...)
+			go watcher.Watch(ctx, func(paths []string) {
+				fmt.Fprintf(supervisor.Stderr, "restarting, %s changed\n", strings.Join(paths, ", "))
+				supervisor.Restart()
+			})
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = supervisor.Run(ctx)
+		}()
+	}
+
+	err = serve(ctx, server)
+	stop()
+	wg.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
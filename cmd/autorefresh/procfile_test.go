@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcfile(t *testing.T) {
+	t.Parallel()
+	processes, err := parseProcfile(strings.NewReader(`
+# the application
+web: go run ./cmd/server -port 3000
+css:   tailwindcss -i in.css -o out.css --watch
+`))
+	if err != nil {
+		t.Fatalf("Could not parse Procfile. %v", err)
+	}
+	expected := []process{
+		{name: "web", command: "go run ./cmd/server -port 3000"},
+		{name: "css", command: "tailwindcss -i in.css -o out.css --watch"},
+	}
+	if len(processes) != len(expected) {
+		t.Fatalf("Expected %d processes, got %v", len(expected), processes)
+	}
+	for i := range expected {
+		if processes[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected[i], processes[i])
+		}
+	}
+
+	for _, invalid := range []string{"web", "web:", "web: a\nweb: b"} {
+		if _, err := parseProcfile(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("Expected an error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+// proxyFlags are the flags shared by the commands that put a Proxy in front of the application.
+type proxyFlags struct {
+	listen      listenFlags
+	upstream    *string
+	path        *string
+	refreshRate *uint
+	timeout     *time.Duration
+	inject      *bool
+}
+
+func addProxyFlags(fs *flag.FlagSet) proxyFlags {
+	return proxyFlags{
+		listen:      addListenFlags(fs),
+		upstream:    fs.String("upstream", "http://localhost:3000", "URL of the application"),
+		path:        fs.String("path", defaultPath, "path to serve the reloader socket at"),
+		refreshRate: fs.Uint("refresh-rate", 250, "reconnect interval of the browser script in milliseconds"),
+		timeout:     fs.Duration("timeout", time.Second*30, "how long to hold requests while the application is down"),
+		inject:      fs.Bool("inject", true, "inject the reload script into the application's HTML documents"),
+	}
+}
+
+func (f proxyFlags) proxy() (*autorefresh.Proxy, error) {
+	reloader, err := autorefresh.New(nil, *f.path, *f.refreshRate)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := autorefresh.NewProxy(*f.upstream, reloader)
+	if err != nil {
+		return nil, err
+	}
+	proxy.Timeout = *f.timeout
+	proxy.Inject = *f.inject
+	return proxy, nil
+}
+
+func runProxy(args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	flags := addProxyFlags(fs)
+	_ = fs.Parse(args)
+
+	proxy, err := flags.proxy()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go proxy.Monitor(ctx)
+
+	server, err := flags.listen.server(proxy, proxy.Reloader)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "proxying %s to %s\n", flags.listen.url(), proxy.Upstream)
+	if err := flags.listen.printLAN(); err != nil {
+		return err
+	}
+	return serve(ctx, server)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autorefresh run [flags] -- command [args...]")
+		fs.PrintDefaults()
+	}
+	flags := addProxyFlags(fs)
+	minUptime := fs.Duration("min-uptime", time.Second*5, "exits sooner than this after starting count as crashes")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+
+	proxy, err := flags.proxy()
+	if err != nil {
+		return err
+	}
+	supervisor := autorefresh.NewSupervisor(proxy.Reloader, fs.Arg(0), fs.Args()[1:]...)
+	supervisor.MinUptime = *minUptime
+
+	server, err := flags.listen.server(proxy, proxy.Reloader)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "proxying %s to %s\n", flags.listen.url(), proxy.Upstream)
+	if err := flags.listen.printLAN(); err != nil {
+		return err
+	}
+
+	// The command is only started once nothing else can fail, so it is always stopped before returning.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go proxy.Monitor(ctx)
+	supervised := make(chan error, 1)
+	go func() { supervised <- supervisor.Run(ctx) }()
+
+	err = serve(ctx, server)
+	stop()
+	return errors.Join(err, <-supervised)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autorefresh serve [flags] [dir]")
+		fs.PrintDefaults()
+	}
+	listen := addListenFlags(fs)
+	reloadPath := fs.String("path", defaultPath, "path to serve the reloader socket at")
+	refreshRate := fs.Uint("refresh-rate", 250, "reconnect interval of the browser script in milliseconds")
+	_ = fs.Parse(args)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	reloader, err := autorefresh.New(nil, *reloadPath, *refreshRate)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle(reloader.Path+"/", reloader)
+	mux.Handle("/", reloader.Inject(http.FileServer(http.Dir(dir))))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go autorefresh.NewWatcher(dir).Watch(ctx, func(paths []string) {
+		reloadChanges(reloader, paths)
+	})
+
+	server, err := listen.server(mux, reloader)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "serving %s on %s\n", dir, listen.url())
+	if err := listen.printLAN(); err != nil {
+		return err
+	}
+	err = serve(ctx, server)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// reloadChanges swaps the stylesheets in connected browsers when only stylesheets changed, and
+// reloads them otherwise.
+func reloadChanges(reloader *autorefresh.PageReloader, paths []string) {
+	var stylesheets []string
+	for _, p := range paths {
+		if path.Ext(p) != ".css" {
+			reloader.Reload()
+			return
+		}
+		stylesheets = append(stylesheets, "/"+p)
+	}
+	reloader.ReloadCSS(stylesheets...)
+}
@@ -0,0 +1,289 @@
+package autorefresh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lavigneer/browser-autorefresh/internal/htmltoken"
+)
+
+// Crawler requests the GET routes of an application in-process after it started, and reports the
+// pages that broke to the browsers connected to a PageReloader, so broken pages show up without
+// clicking around. Run Crawl once the server is ready, e.g. right after NotifyReady.
+type Crawler struct {
+	Reloader *PageReloader
+	// Handler is the application, it is called directly without going through the network.
+	Handler http.Handler
+	// Routes are the paths crawled, "/" when empty. Paths without a leading slash get one.
+	Routes []string
+	// Discover crawls the internal links found in the pages as well, up to MaxPages pages. Without
+	// it, links are only checked for their status.
+	Discover bool
+	MaxPages int
+	// Host is the host of the requests, links to other hosts are not checked.
+	Host string
+	// Skip leaves out the paths it returns true for, such as a logout route changing state on GET.
+	Skip func(path string) bool
+
+	mu       sync.Mutex
+	reported map[string]bool
+}
+
+// CrawlResult is the outcome of requesting a page or link.
+type CrawlResult struct {
+	Path   string
+	Status int
+	// Referrer is the page the path was linked from, empty for Routes.
+	Referrer string
+	// Problems describe what is wrong with the page, nothing when it is fine.
+	Problems []string
+}
+
+// templateErrorPattern matches the errors of text/template and html/template, which end up in the
+// body when a handler writes them after a template failed halfway.
+var templateErrorPattern = regexp.MustCompile(`(?:html/)?template: [^\s:]+:\d+(?::\d+)?: [^\n<]*`)
+
+// maxCrawlRedirects is the number of redirects followed before a path is reported as a redirect loop.
+const maxCrawlRedirects = 10
+
+// NewCrawler creates a Crawler of the routes of handler, reporting to reloader.
+func NewCrawler(reloader *PageReloader, handler http.Handler, routes ...string) *Crawler {
+	return &Crawler{Reloader: reloader, Handler: handler, Routes: routes, MaxPages: 200, Host: "localhost"}
+}
+
+// Crawl requests the routes and the links found in them, reports the broken ones as problems and
+// clears the problems of the previous crawl that are fixed. It returns the results of every path
+// requested, in the order they were requested.
+func (c *Crawler) Crawl(ctx context.Context) []CrawlResult {
+	routes := c.Routes
+	if len(routes) == 0 {
+		routes = []string{"/"}
+	}
+	type link struct{ path, referrer string }
+	queue := make([]link, 0, len(routes))
+	seen := map[string]bool{}
+	for _, r := range routes {
+		if !strings.HasPrefix(r, "/") {
+			r = "/" + r
+		}
+		if !seen[r] {
+			seen[r] = true
+			queue = append(queue, link{path: r})
+		}
+	}
+	var results []CrawlResult
+	pages := 0
+	for len(queue) > 0 && ctx.Err() == nil {
+		next := queue[0]
+		queue = queue[1:]
+		// Pages are parsed for links when they are routes or discovered within the limit.
+		parse := next.referrer == "" || (c.Discover && pages < c.MaxPages)
+		result, links := c.request(ctx, next.path, next.referrer, parse)
+		results = append(results, result)
+		if parse {
+			pages++
+		}
+		for _, l := range links {
+			if !seen[l] && (c.Skip == nil || !c.Skip(l)) {
+				seen[l] = true
+				queue = append(queue, link{path: l, referrer: next.path})
+			}
+		}
+	}
+	c.report(results)
+	return results
+}
+
+// request gets path, and returns the internal links of the page when parse is set and it is HTML.
+func (c *Crawler) request(ctx context.Context, path, referrer string, parse bool) (CrawlResult, []string) {
+	result := CrawlResult{Path: path, Referrer: referrer}
+	target := path
+	for redirects := 0; ; redirects++ {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+c.Host+target, nil)
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("invalid path: %v", err))
+			return result, nil
+		}
+		r.RequestURI = target
+		r.RemoteAddr = "127.0.0.1:0"
+		r.Header.Set("Accept", "text/html,*/*")
+		r.Header.Set("User-Agent", "autorefresh-crawler")
+		rec := &crawlRecorder{header: http.Header{}}
+		if v := c.serve(rec, r); v != nil {
+			// Like net/http, answer with 500 for handlers that panic.
+			result.Status = http.StatusInternalServerError
+			result.Problems = append(result.Problems, fmt.Sprintf("panic: %v", v))
+			return result, nil
+		}
+		result.Status = rec.status
+		header := rec.sent
+		if location := header.Get("Location"); rec.status >= 300 && rec.status < 400 && location != "" {
+			next, ok := c.internal(target, location)
+			switch {
+			case !ok:
+				result.Problems = append(result.Problems, fmt.Sprintf("redirects to %s, outside of the application", location))
+			case redirects >= maxCrawlRedirects:
+				result.Problems = append(result.Problems, fmt.Sprintf("too many redirects, stopped at %s", next))
+			default:
+				target = next
+				continue
+			}
+			return result, nil
+		}
+		if rec.status < 200 || rec.status >= 300 {
+			if referrer != "" {
+				result.Problems = append(result.Problems, fmt.Sprintf("broken link: %s returned %d", path, rec.status))
+			} else {
+				result.Problems = append(result.Problems, fmt.Sprintf("returned %d", rec.status))
+			}
+		}
+		if !isHTML(header) {
+			return result, nil
+		}
+		body := rec.body.Bytes()
+		for _, m := range templateErrorPattern.FindAll(body, -1) {
+			result.Problems = append(result.Problems, strings.TrimSpace(string(m)))
+		}
+		tokens := htmltoken.Tokenize(body)
+		if unterminated(tokens) {
+			result.Problems = append(result.Problems, "document ends before </html>, rendering probably failed halfway")
+		}
+		if !parse {
+			return result, nil
+		}
+		var links []string
+		for _, t := range tokens {
+			if t.Kind != htmltoken.StartTag {
+				continue
+			}
+			for _, attr := range []string{"href", "src"} {
+				if v, ok := t.Attr(attr); ok {
+					if l, ok := c.internal(target, v); ok {
+						links = append(links, l)
+					}
+				}
+			}
+		}
+		return result, links
+	}
+}
+
+// serve calls the Handler, and returns the value it panicked with, if any.
+func (c *Crawler) serve(w *crawlRecorder, r *http.Request) (panicked any) {
+	defer func() {
+		panicked = recover()
+	}()
+	c.Handler.ServeHTTP(w, r)
+	w.finish()
+	return nil
+}
+
+// crawlRecorder records the response of a crawled page.
+type crawlRecorder struct {
+	header http.Header
+	// sent holds the headers as they were when the status was written, handlers may change them
+	// afterwards, e.g. with http.Error.
+	sent   http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *crawlRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *crawlRecorder) WriteHeader(status int) {
+	if w.status != 0 || status < 200 {
+		return
+	}
+	w.status = status
+	w.sent = w.header.Clone()
+}
+
+func (w *crawlRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		// Like net/http, sniff the content type of responses that do not set one.
+		if w.header.Get("Content-Type") == "" {
+			w.header.Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+// finish records the response of handlers that wrote nothing as net/http sends it.
+func (w *crawlRecorder) finish() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// internal resolves ref against the page at base, and reports whether it refers to the application.
+// Fragments are dropped, so they do not count as separate links.
+func (c *Crawler) internal(base, ref string) (string, bool) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", false
+	}
+	u = b.ResolveReference(u)
+	if (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") || (u.Host != "" && u.Host != c.Host) {
+		return "", false
+	}
+	u.Scheme, u.Host, u.Fragment = "", "", ""
+	if u.Path == "" {
+		return "", false
+	}
+	return u.RequestURI(), true
+}
+
+// unterminated reports whether a document that opened an html element did not close it.
+func unterminated(tokens []htmltoken.Token) bool {
+	opened, closed := false, false
+	for _, t := range tokens {
+		if t.Name == "html" {
+			opened = opened || t.Kind == htmltoken.StartTag
+			closed = closed || t.Kind == htmltoken.EndTag
+		}
+	}
+	return opened && !closed
+}
+
+// report shows a problem for each broken path and clears the ones reported by the previous crawl.
+func (c *Crawler) report(results []CrawlResult) {
+	broken := map[string]bool{}
+	for _, r := range results {
+		if len(r.Problems) == 0 {
+			continue
+		}
+		key := "crawl:" + r.Path
+		broken[key] = true
+		problem := Problem{Key: key, Title: "GET " + r.Path + ": " + r.Problems[0], Detail: strings.Join(r.Problems, "\n")}
+		if r.Referrer != "" {
+			problem.Level = "warning"
+			problem.Detail += "\nlinked from " + r.Referrer
+		}
+		c.Reloader.ReportProblem(problem)
+	}
+	c.mu.Lock()
+	var fixed []string
+	for key := range c.reported {
+		if !broken[key] {
+			fixed = append(fixed, key)
+		}
+	}
+	c.reported = broken
+	c.mu.Unlock()
+	sort.Strings(fixed)
+	for _, key := range fixed {
+		c.Reloader.ClearProblem(key)
+	}
+}
@@ -0,0 +1,82 @@
+package autorefresh_test
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestCrawler(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	broken := template.Must(template.New("broken.html").Parse("<html><body>{{ .Missing.Field }}</body></html>"))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body><a href="/old">old</a> <a href="broken#top">broken</a> <a href="https://example.com/">out</a> <img src="/missing.png"> <a href="/panic">panic</a> <a href="/loop">loop</a> <a href="/away">away</a></body></html>`)
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var counts map[string]int
+		counts["views"]++
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/login", http.StatusFound)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<html><body><a href="/logout">logout</a></body></html>`)
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Skipped path was requested")
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		if err := broken.Execute(w, map[string]any{"Missing": 1}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	crawler := autorefresh.NewCrawler(reloader, mux, "/", "new")
+	crawler.Discover = true
+	crawler.Skip = func(path string) bool { return path == "/logout" }
+
+	results := crawler.Crawl(context.Background())
+	var paths []string
+	problems := map[string]int{}
+	for _, r := range results {
+		paths = append(paths, r.Path)
+		problems[r.Path] = len(r.Problems)
+	}
+	if expected := []string{"/", "/new", "/old", "/broken", "/missing.png", "/panic", "/loop", "/away"}; !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected to crawl %v, crawled %v", expected, paths)
+	}
+	if problems["/"] != 0 || problems["/new"] != 0 || problems["/old"] != 0 || problems["/broken"] == 0 || problems["/missing.png"] != 1 {
+		t.Fatalf("Unexpected problems %+v", results)
+	}
+	for _, r := range results[5:] {
+		if len(r.Problems) != 1 {
+			t.Fatalf("Unexpected problems %+v", r)
+		}
+	}
+	if panicked := results[5]; panicked.Status != http.StatusInternalServerError || !strings.HasPrefix(panicked.Problems[0], "panic: ") {
+		t.Fatalf("Panic was not reported %+v", panicked)
+	}
+	if !strings.Contains(results[6].Problems[0], "too many redirects") || !strings.Contains(results[7].Problems[0], "outside of the application") {
+		t.Fatalf("Redirects were not reported %+v", results[6:])
+	}
+}
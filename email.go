@@ -0,0 +1,277 @@
+package autorefresh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// Email is an email template registered with an EmailPreview. Its files are parsed for every
+// preview, so edits show up without restarting the application.
+type Email struct {
+	Name string
+	// HTMLFiles are parsed with html/template and TextFiles with text/template. The first file of
+	// each is executed, and a template named "subject" in either is used as the subject line.
+	HTMLFiles []string
+	TextFiles []string
+	// Fixtures are the named data sets the email is previewed with.
+	Fixtures map[string]any
+	// FixtureFile optionally names a JSON file holding an object of additional named data sets.
+	FixtureFile string
+}
+
+// EmailPreview is a handler listing registered email templates and rendering the HTML and plain
+// text parts of each side by side for a chosen data set. The preview pages include the reload script
+// and Watch reloads them when the templates or fixture files change.
+//
+// It serves paths relative to where it is mounted, use http.StripPrefix to mount it below a prefix.
+type EmailPreview struct {
+	Reloader *PageReloader
+	// Interval is how often Watch polls the files of the emails.
+	Interval time.Duration
+
+	mu     sync.Mutex
+	emails map[string]Email
+}
+
+// NewEmailPreview creates an EmailPreview whose pages connect to reloader.
+func NewEmailPreview(reloader *PageReloader) *EmailPreview {
+	return &EmailPreview{Reloader: reloader, Interval: time.Millisecond * 500, emails: map[string]Email{}}
+}
+
+// Register adds an email template to the preview.
+func (e *EmailPreview) Register(email Email) error {
+	if email.Name == "" || strings.Contains(email.Name, "/") {
+		return fmt.Errorf("%w: invalid email name %q", ErrInvalidParameters, email.Name)
+	}
+	if len(email.HTMLFiles) == 0 && len(email.TextFiles) == 0 {
+		return fmt.Errorf("%w: email %q has no template files", ErrInvalidParameters, email.Name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.emails[email.Name]; ok {
+		return fmt.Errorf("%w: email %q is already registered", ErrInvalidParameters, email.Name)
+	}
+	e.emails[email.Name] = email
+	return nil
+}
+
+// Watch reloads the previews when the template or fixture files of an email change, until ctx is
+// cancelled. The files of emails registered while it runs are watched as well.
+func (e *EmailPreview) Watch(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	previous := e.scan()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := e.scan()
+		changed := len(current) != len(previous)
+		for file, state := range current {
+			if old, ok := previous[file]; !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+				changed = true
+			}
+		}
+		previous = current
+		if changed {
+			e.Reloader.Reload()
+		}
+	}
+}
+
+// scan returns the state of the files of the registered emails, missing files have a zero state.
+func (e *EmailPreview) scan() map[string]fileState {
+	var files []string
+	e.mu.Lock()
+	for _, email := range e.emails {
+		files = append(files, email.HTMLFiles...)
+		files = append(files, email.TextFiles...)
+		if email.FixtureFile != "" {
+			files = append(files, email.FixtureFile)
+		}
+	}
+	e.mu.Unlock()
+	states := make(map[string]fileState, len(files))
+	for _, f := range files {
+		var state fileState
+		if info, err := os.Stat(f); err == nil {
+			state = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		states[f] = state
+	}
+	return states
+}
+
+type emailPreviewPage struct {
+	Script htmltemplate.HTML
+	// Base is the path the preview is mounted at, without a trailing slash.
+	Base     string
+	Emails   []string
+	Email    string
+	Fixtures []string
+	Fixture  string
+	Subject  string
+	HTML     string
+	Text     string
+	Errors   []string
+}
+
+var emailPreviewTemplate = htmltemplate.Must(htmltemplate.New("email-preview").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>{{ if .Email }}{{ .Email }} - {{ end }}Email preview</title>
+		<style>
+			body { margin: 0; font: 14px/1.5 system-ui, sans-serif; display: flex; height: 100vh; }
+			nav { width: 14rem; padding: 1rem; background: #f6f8fa; overflow: auto; }
+			nav a { display: block; }
+			nav a.current { font-weight: bold; }
+			main { flex: 1; display: flex; flex-direction: column; padding: 1rem; min-width: 0; }
+			.parts { flex: 1; display: flex; gap: 1rem; min-height: 0; }
+			.parts > * { flex: 1; border: 1px solid #d1d9e0; margin: 0; overflow: auto; }
+			pre { padding: 1rem; white-space: pre-wrap; }
+			.error { color: #a11111; white-space: pre-wrap; }
+		</style>
+		{{ .Script }}
+	</head>
+	<body>
+		<nav>
+			<h3>Emails</h3>
+			{{ range .Emails }}<a href="{{ $.Base }}/{{ . }}"{{ if eq . $.Email }} class="current"{{ end }}>{{ . }}</a>{{ else }}<p>No emails registered.</p>{{ end }}
+		</nav>
+		<main>
+			{{ if .Email }}
+			<div>
+				{{ range .Fixtures }}<a href="?fixture={{ . }}"{{ if eq . $.Fixture }} class="current"{{ end }}>{{ . }}</a> {{ end }}
+			</div>
+			<h2>{{ .Subject }}</h2>
+			{{ range .Errors }}<p class="error">{{ . }}</p>{{ end }}
+			<div class="parts">
+				<iframe title="HTML part" srcdoc="{{ .HTML }}"></iframe>
+				<pre>{{ .Text }}</pre>
+			</div>
+			{{ end }}
+		</main>
+	</body>
+</html>
+`))
+
+func (e *EmailPreview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := emailPreviewPage{Base: mountPath(r)}
+	var script bytes.Buffer
+	if err := e.Reloader.Template.Execute(&script, nil); err == nil {
+		page.Script = htmltemplate.HTML(script.String())
+	}
+	e.mu.Lock()
+	for name := range e.emails {
+		page.Emails = append(page.Emails, name)
+	}
+	email, ok := e.emails[strings.Trim(r.URL.Path, "/")]
+	e.mu.Unlock()
+	sort.Strings(page.Emails)
+	if r.URL.Path != "/" && r.URL.Path != "" && !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if ok {
+		e.render(&page, email, r.URL.Query().Get("fixture"))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = emailPreviewTemplate.Execute(w, page)
+}
+
+// mountPath returns the prefix stripped from the path of r before it reached the handler, e.g. by
+// http.StripPrefix, without a trailing slash.
+func mountPath(r *http.Request) string {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(u.Path, r.URL.Path), "/")
+}
+
+func (e *EmailPreview) render(page *emailPreviewPage, email Email, fixture string) {
+	page.Email = email.Name
+	fixtures, err := email.fixtures()
+	if err != nil {
+		page.Errors = append(page.Errors, err.Error())
+	}
+	for name := range fixtures {
+		page.Fixtures = append(page.Fixtures, name)
+	}
+	sort.Strings(page.Fixtures)
+	if _, ok := fixtures[fixture]; !ok && len(page.Fixtures) > 0 {
+		fixture = page.Fixtures[0]
+	}
+	page.Fixture = fixture
+	data := fixtures[fixture]
+
+	if len(email.HTMLFiles) > 0 {
+		var b bytes.Buffer
+		t, err := htmltemplate.ParseFiles(email.HTMLFiles...)
+		if err == nil {
+			err = t.Execute(&b, data)
+			if subject := t.Lookup("subject"); subject != nil && err == nil {
+				var s bytes.Buffer
+				err = subject.Execute(&s, data)
+				page.Subject = s.String()
+			}
+		}
+		if err != nil {
+			page.Errors = append(page.Errors, err.Error())
+		}
+		page.HTML = b.String()
+	}
+	if len(email.TextFiles) > 0 {
+		var b bytes.Buffer
+		t, err := texttemplate.ParseFiles(email.TextFiles...)
+		if err == nil {
+			err = t.Execute(&b, data)
+			if subject := t.Lookup("subject"); subject != nil && err == nil {
+				var s bytes.Buffer
+				err = subject.Execute(&s, data)
+				page.Subject = s.String()
+			}
+		}
+		if err != nil {
+			page.Errors = append(page.Errors, err.Error())
+		}
+		page.Text = b.String()
+	}
+}
+
+// fixtures returns the data sets of the email, reading FixtureFile when there is one.
+func (email Email) fixtures() (map[string]any, error) {
+	fixtures := map[string]any{}
+	for name, data := range email.Fixtures {
+		fixtures[name] = data
+	}
+	if email.FixtureFile == "" {
+		return fixtures, nil
+	}
+	b, err := os.ReadFile(email.FixtureFile)
+	if err != nil {
+		return fixtures, err
+	}
+	var fromFile map[string]any
+	if err := json.Unmarshal(b, &fromFile); err != nil {
+		return fixtures, fmt.Errorf("%s: %w", email.FixtureFile, err)
+	}
+	for name, data := range fromFile {
+		fixtures[name] = data
+	}
+	return fixtures, nil
+}
@@ -0,0 +1,120 @@
+package autorefresh_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestEmailPreview(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	files := map[string]string{
+		"welcome.html":  `{{ define "subject" }}Welcome {{ .Name }}{{ end }}<p>Hello <b>{{ .Name }}</b></p>`,
+		"welcome.txt":   `Hello {{ .Name }}`,
+		"fixtures.json": `{"bob": {"Name": "Bob"}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	preview := autorefresh.NewEmailPreview(reloader)
+	err = preview.Register(autorefresh.Email{
+		Name:        "welcome",
+		HTMLFiles:   []string{filepath.Join(dir, "welcome.html")},
+		TextFiles:   []string{filepath.Join(dir, "welcome.txt")},
+		Fixtures:    map[string]any{"alice": map[string]string{"Name": "Alice"}},
+		FixtureFile: filepath.Join(dir, "fixtures.json"),
+	})
+	if err != nil {
+		t.Fatalf("Could not register email. %v", err)
+	}
+	if err := preview.Register(autorefresh.Email{Name: "welcome", TextFiles: []string{"a"}}); err == nil {
+		t.Fatal("Registering an email twice should fail")
+	}
+
+	mounted := http.StripPrefix("/__dev/emails", preview)
+	rec := httptest.NewRecorder()
+	mounted.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/__dev/emails/welcome?fixture=bob", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`<a href="/__dev/emails/welcome" class="current">`,
+		"<h2>Welcome Bob</h2>",
+		"srcdoc=\"&lt;p&gt;Hello &lt;b&gt;Bob&lt;/b&gt;&lt;/p&gt;\"",
+		"<pre>Hello Bob</pre>",
+		"?fixture=alice",
+		"new WebSocket(",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Preview does not contain %q. Rendered %s", expected, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	preview.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown email, got %d", rec.Code)
+	}
+}
+
+func TestEmailPreviewWatchesEmailsRegisteredLater(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "reset.txt")
+	if err := os.WriteFile(file, []byte("Reset"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	preview := autorefresh.NewEmailPreview(reloader)
+	preview.Interval = time.Millisecond * 20
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go preview.Watch(ctx)
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	// Give the server a moment to register the socket.
+	time.Sleep(time.Millisecond * 50)
+
+	if err := preview.Register(autorefresh.Email{Name: "reset", TextFiles: []string{file}}); err != nil {
+		t.Fatalf("Could not register email. %v", err)
+	}
+	reload := func(what string) {
+		var m struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		for m.Type != "reload" {
+			if err := wsjson.Read(ctx, socket, &m); err != nil {
+				t.Fatalf("Preview was not reloaded after %s. %v", what, err)
+			}
+		}
+	}
+	reload("registering an email")
+	if err := os.WriteFile(file, []byte("Reset your password"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reload("editing its template")
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	autorefresh "github.com/lavigneer/browser-autorefresh"
@@ -42,8 +46,28 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	err := server.ListenAndServe()
+	// Use the listener of a supervisor when there is one, so connections wait while restarting
+	l, err := autorefresh.Listen("tcp", server.Addr)
 	if err != nil {
 		fmt.Printf("%v", err)
+		return
 	}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-ctx.Done()
+		// Drain in-flight requests, then send the browsers to the new process
+		_ = server.Shutdown(context.Background())
+		a.Close()
+	}()
+	_ = autorefresh.NotifyReady()
+
+	err = server.Serve(l)
+	if err != http.ErrServerClosed {
+		fmt.Printf("%v", err)
+		return
+	}
+	<-drained
 }
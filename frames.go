@@ -0,0 +1,121 @@
+package autorefresh
+
+import "html/template"
+
+// reloadTarget is the data of reload messages that only reload part of the page.
+type reloadTarget struct {
+	Target string `json:"target"`
+}
+
+// ReloadFrames tells the connected browsers to reload the innermost documents only: with
+// CoordinateFrames set, framed pages reload themselves while the page embedding them stays as it is.
+// Pages without frames reload as they do for Reload, which reloads whole pages, frames included.
+func (p *PageReloader) ReloadFrames() {
+	p.Broadcast(Message{Type: "reload", Data: reloadTarget{Target: "frame"}})
+}
+
+// framesScript coordinates the pages including the script that are framed by another page including
+// it, so they do not reload independently. A framed page asks the top window to connect for it, and
+// connects itself when the top window does not answer, e.g. when it does not include the script,
+// connects to another reloader or is of another origin. Only frames of the same origin as the top
+// window share its socket, so other pages can neither send messages through it nor read its traffic.
+// The top window owns the socket: it forwards the messages it receives to its frames and sends the
+// messages of its frames. Whole page reloads are left to the top window, which reloads its frames
+// along with it, while frame reloads are forwarded to the frames only.
+const framesScript template.JS = `
+		(function () {
+			const url = socketURL(config.path);
+			const origin = window.location.origin;
+			const frames = [];
+
+			if (window.top !== window.self) {
+				let parent = false;
+				let standalone = false;
+				window.addEventListener("message", function (event) {
+					const m = event.data;
+					if (event.source !== window.top || event.origin !== origin || !m || m.autorefresh === undefined || m.url !== url) {
+						return;
+					}
+					if (standalone) {
+						return;
+					}
+					if (m.autorefresh === "welcome") {
+						parent = true;
+					} else if (m.autorefresh === "open") {
+						autorefresh.emit("open");
+					} else if (m.autorefresh === "message") {
+						autorefresh.emit(m.message.type, m.message.data);
+					}
+				});
+				const send = autorefresh.send;
+				autorefresh.send = function (type, data) {
+					if (parent) {
+						window.top.postMessage({ autorefresh: "send", url: url, message: { type: type, data: data } }, origin);
+					}
+				};
+				window.addEventListener("pagehide", function () {
+					if (parent) {
+						window.top.postMessage({ autorefresh: "bye", url: url }, origin);
+					}
+				});
+				const connect = autorefresh.connect;
+				autorefresh.connect = function () {
+					// Frames with an opaque origin, such as sandboxed ones, cannot share the socket.
+					if (origin !== "null") {
+						window.top.postMessage({ autorefresh: "hello", url: url }, origin);
+					}
+					setTimeout(function () {
+						if (!parent) {
+							standalone = true;
+							autorefresh.send = send;
+							connect();
+						}
+					}, 1000);
+				};
+				return;
+			}
+
+			window.addEventListener("message", function (event) {
+				const m = event.data;
+				if (event.origin !== origin || !m || m.autorefresh === undefined || m.url !== url || event.source === null) {
+					return;
+				}
+				const index = frames.indexOf(event.source);
+				if (m.autorefresh === "hello") {
+					if (index < 0) {
+						frames.push(event.source);
+					}
+					event.source.postMessage({ autorefresh: "welcome", url: url }, origin);
+					if (socket !== null) {
+						event.source.postMessage({ autorefresh: "open", url: url }, origin);
+					}
+				} else if (m.autorefresh === "bye" && index >= 0) {
+					frames.splice(index, 1);
+				} else if (m.autorefresh === "send" && index >= 0) {
+					autorefresh.send(m.message.type, m.message.data);
+				}
+			});
+			function forward(m) {
+				frames.forEach(function (frame) {
+					frame.postMessage(Object.assign({ url: url }, m), origin);
+				});
+			}
+			autorefresh.on("open", function () {
+				forward({ autorefresh: "open" });
+			});
+			const receive = autorefresh.receive;
+			autorefresh.receive = function (message) {
+				if (message.type === "reload") {
+					const frame = message.data && message.data.target === "frame";
+					if (frame && frames.length > 0) {
+						forward({ autorefresh: "message", message: message });
+					} else {
+						receive(message);
+					}
+					return;
+				}
+				receive(message);
+				forward({ autorefresh: "message", message: message });
+			};
+		})();
+`
@@ -0,0 +1,56 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestReloadFrames(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	reloader.CoordinateFrames = true
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if !strings.Contains(script.String(), `autorefresh: "hello"`) || !strings.Contains(script.String(), `"path":"/__reload"`) {
+		t.Fatalf("Frames script was not included. Rendered %s", script.String())
+	}
+
+	// Retained problems are sent once the socket is registered, which tells the test it is.
+	reloader.ReportProblem(autorefresh.Problem{Key: "registered", Title: "registered"})
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	var m struct {
+		Type string `json:"type"`
+		Data struct {
+			Target string `json:"target"`
+		} `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil || m.Type != "problem" {
+		t.Fatalf("Socket was not registered, got %+v %v", m, err)
+	}
+
+	reloader.ReloadFrames()
+	if err := wsjson.Read(ctx, socket, &m); err != nil || m.Type != "reload" || m.Data.Target != "frame" {
+		t.Fatalf("Expected a frame reload, got %+v %v", m, err)
+	}
+}
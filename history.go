@@ -0,0 +1,232 @@
+package autorefresh
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavigneer/browser-autorefresh/internal/diff"
+)
+
+// RenderHistory keeps the HTML bodies rendered for each route by its Middleware, and serves a view at
+// Path/history of the PageReloader comparing the page before and after the most recent reload side
+// by side. The last render between two reloads is kept, so the view compares the route as it was
+// last rendered before a reload with how it was last rendered after it.
+type RenderHistory struct {
+	Reloader *PageReloader
+	// Size is the number of bodies kept per route.
+	Size int
+
+	mu      sync.Mutex
+	renders map[string][]render
+	// reloads counts the reloads observed, renders between the same two reloads share the count.
+	reloads int
+}
+
+type render struct {
+	time    time.Time
+	body    []byte
+	reloads int
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// NewRenderHistory creates a RenderHistory whose view is served by reloader. It fails when a plugin
+// already uses the "history" path.
+func NewRenderHistory(reloader *PageReloader) (*RenderHistory, error) {
+	h := &RenderHistory{Reloader: reloader, Size: 10, renders: map[string][]render{}}
+	if err := reloader.handle("history", h); err != nil {
+		return nil, err
+	}
+	reloader.observe(func(m Message) {
+		if m.Type == "reload" {
+			h.mu.Lock()
+			h.reloads++
+			h.mu.Unlock()
+		}
+	})
+	return h, nil
+}
+
+// Middleware keeps the successful HTML responses of next to GET requests, by URL path.
+func (h *RenderHistory) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		iw := &injectWriter{ResponseWriter: w, script: func() []byte { return nil }}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+		if iw.inject && iw.sent == http.StatusOK {
+			h.keep(r.URL.Path, iw.buf.Bytes())
+		}
+	})
+}
+
+func (h *RenderHistory) keep(route string, body []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	renders := h.renders[route]
+	r := render{time: time.Now(), body: append([]byte(nil), body...), reloads: h.reloads}
+	if len(renders) > 0 && renders[len(renders)-1].reloads == h.reloads {
+		renders[len(renders)-1] = r
+		return
+	}
+	h.renders[route] = appendLimited(renders, r, h.Size)
+}
+
+type historyPage struct {
+	Script template.HTML
+	Routes []historyRoute
+	Route  string
+	Before time.Time
+	After  time.Time
+	// Unchanged is set when the route rendered the same before and after the reload.
+	Unchanged bool
+	Rows      []diffRow
+}
+
+type historyRoute struct {
+	Route   string
+	Renders int
+}
+
+// diffRow is a line of the side by side view. Skipped rows stand for unchanged lines left out.
+type diffRow struct {
+	Before, After  string
+	Deleted, Added bool
+	Skipped        int
+}
+
+var historyTemplate = template.Must(template.New("history").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>{{ if .Route }}{{ .Route }} - {{ end }}Render history</title>
+		<style>
+			body { margin: 0; font: 14px/1.5 system-ui, sans-serif; display: flex; min-height: 100vh; }
+			nav { width: 16rem; padding: 1rem; background: #f6f8fa; }
+			nav a { display: block; word-break: break-all; }
+			nav a.current { font-weight: bold; }
+			main { flex: 1; padding: 1rem; min-width: 0; }
+			table { border-collapse: collapse; width: 100%; table-layout: fixed; font: 12px/1.4 monospace; }
+			td { white-space: pre-wrap; word-break: break-all; vertical-align: top; padding: 0 6px; border-right: 1px solid #d1d9e0; }
+			.deleted { background: #ffebe9; }
+			.added { background: #dafbe1; }
+			.skipped { color: #59636e; background: #f6f8fa; text-align: center; }
+		</style>
+		{{ .Script }}
+	</head>
+	<body>
+		<nav>
+			<h3>Routes</h3>
+			{{ range .Routes }}<a href="?route={{ .Route }}"{{ if eq .Route $.Route }} class="current"{{ end }}>{{ .Route }} ({{ .Renders }})</a>{{ else }}<p>Nothing rendered yet.</p>{{ end }}
+		</nav>
+		<main>
+			{{ if .Route }}
+			{{ if .Unchanged }}
+			<p>{{ .Route }} did not change with the last reload.</p>
+			{{ else if .Rows }}
+			<table>
+				<tr><th>Before, {{ .Before.Format "15:04:05" }}</th><th>After, {{ .After.Format "15:04:05" }}</th></tr>
+				{{ range .Rows }}
+				{{ if .Skipped }}
+				<tr><td class="skipped" colspan="2">{{ .Skipped }} unchanged lines</td></tr>
+				{{ else }}
+				<tr><td{{ if .Deleted }} class="deleted"{{ end }}>{{ .Before }}</td><td{{ if .Added }} class="added"{{ end }}>{{ .After }}</td></tr>
+				{{ end }}
+				{{ end }}
+			</table>
+			{{ else }}
+			<p>{{ .Route }} was not rendered since before a reload yet.</p>
+			{{ end }}
+			{{ end }}
+		</main>
+	</body>
+</html>
+`))
+
+// ServeHTTP lists the routes with a history, and compares the renders of the route given by the
+// route query parameter before and after the last reload it was rendered across.
+func (h *RenderHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := historyPage{Route: r.URL.Query().Get("route")}
+	var script bytes.Buffer
+	if err := h.Reloader.Template.Execute(&script, nil); err == nil {
+		page.Script = template.HTML(script.String())
+	}
+	h.mu.Lock()
+	for route, renders := range h.renders {
+		page.Routes = append(page.Routes, historyRoute{Route: route, Renders: len(renders)})
+	}
+	renders := h.renders[page.Route]
+	h.mu.Unlock()
+	sort.Slice(page.Routes, func(i, j int) bool { return page.Routes[i].Route < page.Routes[j].Route })
+	if page.Route != "" && renders == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(renders) >= 2 {
+		before, after := renders[len(renders)-2], renders[len(renders)-1]
+		page.Before, page.After = before.time, after.time
+		page.Unchanged = bytes.Equal(before.body, after.body)
+		page.Rows = diffRows(diff.Lines(strings.Split(string(before.body), "\n"), strings.Split(string(after.body), "\n")))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = historyTemplate.Execute(w, page)
+}
+
+// diffRows lays edits out side by side, pairing deleted lines with the lines inserted in their place
+// and leaving out unchanged lines further than diffContext lines from a change.
+func diffRows(edits []diff.Edit) []diffRow {
+	var rows []diffRow
+	for i := 0; i < len(edits); {
+		if edits[i].Op == diff.Equal {
+			j := i
+			for j < len(edits) && edits[j].Op == diff.Equal {
+				j++
+			}
+			skipStart, skipEnd := i+diffContext, j-diffContext
+			if i == 0 {
+				skipStart = i
+			}
+			if j == len(edits) {
+				skipEnd = j
+			}
+			for k := i; k < j; k++ {
+				switch {
+				case k < skipStart || k >= skipEnd || skipEnd-skipStart <= 1:
+					rows = append(rows, diffRow{Before: edits[k].A, After: edits[k].B})
+				case k == skipStart:
+					rows = append(rows, diffRow{Skipped: skipEnd - skipStart})
+				}
+			}
+			i = j
+			continue
+		}
+		var deleted, inserted []string
+		for ; i < len(edits) && edits[i].Op != diff.Equal; i++ {
+			if edits[i].Op == diff.Delete {
+				deleted = append(deleted, edits[i].A)
+			} else {
+				inserted = append(inserted, edits[i].B)
+			}
+		}
+		for k := 0; k < len(deleted) || k < len(inserted); k++ {
+			row := diffRow{}
+			if k < len(deleted) {
+				row.Before, row.Deleted = deleted[k], true
+			}
+			if k < len(inserted) {
+				row.After, row.Added = inserted[k], true
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
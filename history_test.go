@@ -0,0 +1,69 @@
+package autorefresh_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestRenderHistory(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	history, err := autorefresh.NewRenderHistory(reloader)
+	if err != nil {
+		t.Fatalf("Could not create render history. %v", err)
+	}
+	title := "Before"
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path+"/", reloader)
+	mux.Handle("/", history.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "<html>\n<head>\n<title>"+title+"</title>\n</head>\n<body>\n<p>same</p>\n</body>\n</html>")
+	})))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(url string) string {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed. %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status %d for %s", resp.StatusCode, url)
+		}
+		return string(b)
+	}
+	get(server.URL + "/page")
+	title = "Changed before the reload"
+	get(server.URL + "/page")
+	reloader.Reload()
+	title = "After"
+	get(server.URL + "/page")
+	get(server.URL + "/page")
+
+	view := get(server.URL + reloader.Path + "/history?route=/page")
+	if !strings.Contains(view, `<td class="deleted">&lt;title&gt;Changed before the reload&lt;/title&gt;</td><td class="added">&lt;title&gt;After&lt;/title&gt;</td>`) {
+		t.Fatalf("Change across the reload is not shown side by side. Got %s", view)
+	}
+	if !strings.Contains(view, "/page (2)") {
+		t.Fatalf("Renders between the same reloads were kept. Got %s", view)
+	}
+	if !strings.Contains(view, "new WebSocket(") {
+		t.Fatalf("View does not include the reload script. Got %s", view)
+	}
+
+	reloader.Reload()
+	get(server.URL + "/page")
+	if view := get(server.URL + reloader.Path + "/history?route=/page"); !strings.Contains(view, "did not change with the last reload") {
+		t.Fatalf("Unchanged render was not reported. Got %s", view)
+	}
+}
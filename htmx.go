@@ -0,0 +1,85 @@
+package autorefresh
+
+import "html/template"
+
+// HTMXFragment returns the attribute marking an element as a fragment loaded from url, so
+// PageReloader.HTMX refreshes it from url instead of reloading the page. Add it to the element the
+// fragment template renders, e.g. with a "refreshable" template function:
+//
+//	<div id="cart" {{ refreshable "/cart" }}>...</div>
+func HTMXFragment(url string) template.HTMLAttr {
+	return template.HTMLAttr(`data-autorefresh-fragment="` + template.HTMLEscapeString(url) + `"`)
+}
+
+// htmxScript refreshes pages using htmx in place instead of reloading them, keeping the state built by
+// partial swaps. A page reached through a boosted link gets its boosted request issued again, which
+// also runs the hx-trigger="load" elements of the new content. Other pages get their HTMXFragment
+// elements fetched again, and their hx-trigger="load" elements still in the page issue their request
+// again. Pages with nothing to refresh, pages without htmx, and failed requests fall back to a reload.
+const htmxScript template.JS = `
+		(function () {
+			let boosted = null;
+			function here() {
+				return window.location.pathname + window.location.search;
+			}
+			document.addEventListener("htmx:afterRequest", function (event) {
+				const detail = event.detail;
+				if (detail.boosted && detail.successful && detail.requestConfig && detail.requestConfig.verb === "get") {
+					boosted = detail.pathInfo.finalRequestPath || detail.pathInfo.requestPath;
+				}
+			});
+			document.addEventListener("htmx:historyRestore", function () {
+				boosted = null;
+			});
+
+			function requests() {
+				const url = boosted !== null && new URL(boosted, window.location.href);
+				if (url && url.pathname + url.search === here()) {
+					return [htmx.ajax("GET", here(), { target: document.body, swap: "innerHTML" })];
+				}
+				const pending = [];
+				document.querySelectorAll("[data-autorefresh-fragment]").forEach(function (el) {
+					pending.push(htmx.ajax("GET", el.getAttribute("data-autorefresh-fragment"), { target: el, swap: "outerHTML" }));
+				});
+				document.querySelectorAll("[hx-trigger]").forEach(function (el) {
+					const load = el.getAttribute("hx-trigger").split(",").some(function (trigger) {
+						return trigger.trim().split(/\s+/)[0] === "load";
+					});
+					if (load && el.hasAttribute("hx-get") && !el.closest("[data-autorefresh-fragment]")) {
+						pending.push(htmx.ajax("GET", el.getAttribute("hx-get"), { source: el }));
+					}
+				});
+				return pending;
+			}
+
+			const reload = autorefresh.reload;
+			autorefresh.reload = function () {
+				if (!window.htmx || typeof htmx.ajax !== "function" || !document.body) {
+					reload();
+					return;
+				}
+				const pending = requests();
+				if (pending.length === 0) {
+					reload();
+					return;
+				}
+				let failed = false;
+				function fail() {
+					failed = true;
+				}
+				document.addEventListener("htmx:responseError", fail);
+				document.addEventListener("htmx:sendError", fail);
+				Promise.all(pending).then(function () {
+					return failed;
+				}, function () {
+					return true;
+				}).then(function (failed) {
+					document.removeEventListener("htmx:responseError", fail);
+					document.removeEventListener("htmx:sendError", fail);
+					if (failed) {
+						reload();
+					}
+				});
+			};
+		})();
+`
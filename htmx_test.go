@@ -0,0 +1,38 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestHTMX(t *testing.T) {
+	t.Parallel()
+	tmpl := template.Must(template.New("cart").Funcs(template.FuncMap{"refreshable": autorefresh.HTMXFragment}).Parse(`<div id="cart" {{ refreshable .URL }}>cart</div>`))
+	for url, expected := range map[string]string{
+		"/cart":            `<div id="cart" data-autorefresh-fragment="/cart">cart</div>`,
+		`/cart?a=1&b="2"`:  `<div id="cart" data-autorefresh-fragment="/cart?a=1&amp;b=&#34;2&#34;">cart</div>`,
+		`/cart" onload="x`: `<div id="cart" data-autorefresh-fragment="/cart&#34; onload=&#34;x">cart</div>`,
+	} {
+		var fragment bytes.Buffer
+		if err := tmpl.Execute(&fragment, map[string]string{"URL": url}); err != nil {
+			t.Fatalf("Could not render fragment. %v", err)
+		}
+		if fragment.String() != expected {
+			t.Fatalf("Expected %s, got %s", expected, fragment.String())
+		}
+	}
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	without, _ := renderScript(t, reloader)
+	reloader.HTMX = true
+	with, _ := renderScript(t, reloader)
+	if without.HTMX || !with.HTMX || len(with.Fragments) != len(without.Fragments)+1 {
+		t.Fatalf("Expected HTMX to add its script, got %d fragments without and %d with", len(without.Fragments), len(with.Fragments))
+	}
+}
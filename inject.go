@@ -0,0 +1,171 @@
+package autorefresh
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// InjectScript inserts script into an HTML document, right before the closing head tag when there
+// is one, before the closing body tag otherwise, and at the end of the document as a last resort.
+func InjectScript(html, script []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(html), []byte("</head>"))
+	if i < 0 {
+		i = bytes.LastIndex(bytes.ToLower(html), []byte("</body>"))
+	}
+	if i < 0 {
+		i = len(html)
+	}
+	out := make([]byte, 0, len(html)+len(script))
+	out = append(out, html[:i]...)
+	out = append(out, script...)
+	return append(out, html[i:]...)
+}
+
+// Inject is a middleware that injects the reload script into the HTML responses of next, so pages
+// that are not rendered from the PageReloader's template still refresh themselves. Compressed
+// responses are passed through untouched.
+func (p *PageReloader) Inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && acceptsHTML(r) {
+			// Conditional and partial responses would not contain the script.
+			for _, h := range []string{"Range", "If-Range", "If-Modified-Since", "If-None-Match"} {
+				r.Header.Del(h)
+			}
+		}
+		iw := &injectWriter{ResponseWriter: w, script: p.renderScript}
+		defer iw.finish()
+		next.ServeHTTP(iw, r)
+	})
+}
+
+// renderScript renders the reload script, or nothing when the template fails.
+func (p *PageReloader) renderScript() []byte {
+	var script bytes.Buffer
+	if err := p.Template.Execute(&script, nil); err != nil {
+		return nil
+	}
+	return script.Bytes()
+}
+
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// isHTML reports whether a response with header h can have the script injected into it.
+func isHTML(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "text/html" && h.Get("Content-Encoding") == ""
+}
+
+// injectWriter buffers HTML responses to inject a script into them, passing any other response
+// through as is.
+type injectWriter struct {
+	http.ResponseWriter
+	script func() []byte
+	status int
+	// sent is the status code of the response, once it is known.
+	sent     int
+	decided  bool
+	inject   bool
+	hijacked bool
+	buf      bytes.Buffer
+}
+
+func (w *injectWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.ResponseWriter.WriteHeader(status)
+		if status >= 200 {
+			w.decided = true
+			w.sent = status
+		}
+		return
+	}
+	w.status = status
+}
+
+func (w *injectWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decide(b)
+	}
+	if w.inject {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide determines whether the response is HTML, sniffing its content type like net/http does
+// when the handler did not set one.
+func (w *injectWriter) decide(b []byte) {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(b) > 0 {
+		h.Set("Content-Type", http.DetectContentType(b))
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.sent = w.status
+	w.inject = isHTML(h)
+	if w.inject {
+		h.Del("Content-Length")
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *injectWriter) finish() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		w.decide(nil)
+	}
+	if !w.inject {
+		return
+	}
+	body := InjectScript(w.buf.Bytes(), w.script())
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+func (w *injectWriter) Flush() {
+	if !w.decided {
+		w.decide(nil)
+	}
+	if w.inject {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *injectWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.ResponseWriter does not implement http.Hijacker")
+	}
+	w.hijacked = true
+	return hj.Hijack()
+}
+
+func (w *injectWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,59 @@
+package autorefresh_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestInjectScript(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"<html><head><title>a</title></HEAD><body></body></html>": "<html><head><title>a</title><script></script></HEAD><body></body></html>",
+		"<p>no head</p></body>":                                   "<p>no head</p><script></script></body>",
+		"<p>fragment</p>":                                         "<p>fragment</p><script></script>",
+	}
+	for html, expected := range cases {
+		if got := string(autorefresh.InjectScript([]byte(html), []byte("<script></script>"))); got != expected {
+			t.Fatalf("Expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestInjectMiddleware(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	handler := reloader.Inject(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"a":1}`)
+			return
+		}
+		w.Header().Set("Content-Length", "38")
+		_, _ = io.WriteString(w, "<html><head></head><body></body></html>")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "new WebSocket(") || !strings.HasSuffix(body, "</head><body></body></html>") {
+		t.Fatalf("Script was not injected into the head. Got %s", body)
+	}
+	if rec.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatalf("Content-Length %s does not match the body length %d", rec.Header().Get("Content-Length"), len(body))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/data.json", nil))
+	if rec.Body.String() != `{"a":1}` {
+		t.Fatalf("Non-HTML response was modified. Got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,215 @@
+package autorefresh
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// InspectedValue is a snapshot of a Go value, safe to serialize whatever the value holds: cycles are
+// cut, unexported fields are read without calling their methods, and the snapshot is bounded by
+// InspectLimits.
+type InspectedValue struct {
+	// Type is the Go type of the value.
+	Type string `json:"type"`
+	// Value is the formatted value of scalars, and the String or Error of values implementing
+	// fmt.Stringer or error.
+	Value string `json:"value,omitempty"`
+	// Len is the length of slices, arrays, maps and strings.
+	Len int `json:"len,omitempty"`
+	// Fields are the fields of structs, the entries of maps and the elements of slices and arrays.
+	Fields []InspectedField `json:"fields,omitempty"`
+	// Truncated is the number of fields or characters left out because of the limits.
+	Truncated int `json:"truncated,omitempty"`
+	// Cycle is set for a pointer to a value that contains it, which is not inspected again.
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// InspectedField is a named part of an InspectedValue.
+type InspectedField struct {
+	Name       string          `json:"name"`
+	Unexported bool            `json:"unexported,omitempty"`
+	Value      *InspectedValue `json:"value"`
+}
+
+// InspectLimits bound the size of the snapshot taken by Inspect.
+type InspectLimits struct {
+	// Depth is the nesting depth below which values are left out.
+	Depth int
+	// Items is the number of fields, entries or elements kept per value.
+	Items int
+	// String is the number of bytes kept of strings.
+	String int
+	// Values is the total number of values in the snapshot.
+	Values int
+}
+
+// DefaultInspectLimits are the limits used by the Toolbar.
+var DefaultInspectLimits = InspectLimits{Depth: 8, Items: 100, String: 1000, Values: 2000}
+
+// Inspect takes a snapshot of v within limits. Zero limits are taken from DefaultInspectLimits.
+func Inspect(v any, limits InspectLimits) *InspectedValue {
+	if limits.Depth <= 0 {
+		limits.Depth = DefaultInspectLimits.Depth
+	}
+	if limits.Items <= 0 {
+		limits.Items = DefaultInspectLimits.Items
+	}
+	if limits.String <= 0 {
+		limits.String = DefaultInspectLimits.String
+	}
+	if limits.Values <= 0 {
+		limits.Values = DefaultInspectLimits.Values
+	}
+	in := &inspector{limits: limits, visiting: map[visit]bool{}}
+	return in.inspect(reflect.ValueOf(v), 0)
+}
+
+type inspector struct {
+	limits InspectLimits
+	values int
+	// visiting holds the references on the path to the value being inspected.
+	visiting map[visit]bool
+}
+
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (in *inspector) inspect(v reflect.Value, depth int) *InspectedValue {
+	in.values++
+	if !v.IsValid() {
+		return &InspectedValue{Type: "nil", Value: "nil"}
+	}
+	iv := &InspectedValue{Type: v.Type().String()}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			iv.Value = "nil"
+			return iv
+		}
+	}
+	if v.Kind() == reflect.Interface {
+		return in.inspect(v.Elem(), depth)
+	}
+	iv.Value = describe(v)
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if in.visiting[key] {
+			iv.Cycle = true
+			return iv
+		}
+		in.visiting[key] = true
+		defer delete(in.visiting, key)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := in.inspect(v.Elem(), depth)
+		elem.Type = iv.Type
+		if iv.Value != "" {
+			elem.Value = iv.Value
+		}
+		return elem
+	case reflect.String:
+		s := v.String()
+		iv.Len = len(s)
+		if len(s) > in.limits.String {
+			iv.Truncated = len(s) - in.limits.String
+			s = s[:in.limits.String]
+		}
+		iv.Value = strconv.Quote(s)
+		return iv
+	case reflect.Struct:
+		// Values such as time.Time are better described by their String than by their internals.
+		if iv.Value != "" && !hasExportedFields(v.Type()) {
+			return iv
+		}
+	case reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		if iv.Value == "" {
+			iv.Value = scalar(v)
+		}
+		return iv
+	}
+
+	var n int
+	if v.Kind() == reflect.Struct {
+		n = v.NumField()
+	} else {
+		n = v.Len()
+		iv.Len = n
+	}
+	if n == 0 {
+		return iv
+	}
+	if depth >= in.limits.Depth || in.values >= in.limits.Values {
+		iv.Truncated = n
+		return iv
+	}
+	var keys []reflect.Value
+	if v.Kind() == reflect.Map {
+		keys = v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	}
+	for i := 0; i < n; i++ {
+		if len(iv.Fields) >= in.limits.Items || in.values >= in.limits.Values {
+			iv.Truncated = n - i
+			break
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			f := v.Type().Field(i)
+			iv.Fields = append(iv.Fields, InspectedField{Name: f.Name, Unexported: !f.IsExported(), Value: in.inspect(v.Field(i), depth+1)})
+		case reflect.Map:
+			iv.Fields = append(iv.Fields, InspectedField{Name: fmt.Sprint(keys[i]), Value: in.inspect(v.MapIndex(keys[i]), depth+1)})
+		default:
+			iv.Fields = append(iv.Fields, InspectedField{Name: strconv.Itoa(i), Value: in.inspect(v.Index(i), depth+1)})
+		}
+	}
+	return iv
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// describe returns the Error or String of values implementing error or fmt.Stringer, when they can
+// be called. Values read from unexported fields cannot, and panics are reported as the description.
+func describe(v reflect.Value) (s string) {
+	if !v.CanInterface() {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s = fmt.Sprintf("panic calling String: %v", r)
+		}
+	}()
+	switch x := v.Interface().(type) {
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	}
+	return ""
+}
+
+// scalar formats values that have no parts. Functions, channels and unsafe pointers are described by
+// their type only.
+func scalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return v.Kind().String()
+	}
+	// fmt formats the value held by a reflect.Value, including values of unexported fields.
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,63 @@
+package autorefresh_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+type inspectedUser struct {
+	Name    string
+	Created time.Time
+	Friends []*inspectedUser
+	Tags    map[string]int
+	secret  string
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+	user := &inspectedUser{Name: "ada", Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Tags: map[string]int{"b": 2, "a": 1}, secret: "hidden"}
+	user.Friends = []*inspectedUser{user}
+
+	v := autorefresh.Inspect(map[string]any{"User": user}, autorefresh.InspectLimits{})
+	if _, err := json.Marshal(v); err != nil {
+		t.Fatalf("Snapshot cannot be serialized. %v", err)
+	}
+	u := v.Fields[0].Value
+	if v.Fields[0].Name != "User" || u.Type != "*autorefresh_test.inspectedUser" || len(u.Fields) != 5 {
+		t.Fatalf("Unexpected snapshot %+v", u)
+	}
+	if name := u.Fields[0].Value; name.Value != `"ada"` {
+		t.Fatalf("Unexpected name %+v", name)
+	}
+	if created := u.Fields[1].Value; created.Value != "2020-01-02 03:04:05 +0000 UTC" || created.Fields != nil {
+		t.Fatalf("Expected the time to be described by its String, got %+v", created)
+	}
+	if friend := u.Fields[2].Value.Fields[0].Value; !friend.Cycle {
+		t.Fatalf("Expected the cycle to be cut, got %+v", friend)
+	}
+	if tags := u.Fields[3].Value; tags.Len != 2 || tags.Fields[0].Name != "a" || tags.Fields[0].Value.Value != "1" {
+		t.Fatalf("Expected sorted map entries, got %+v", tags)
+	}
+	if secret := u.Fields[4]; !secret.Unexported || secret.Value.Value != `"hidden"` {
+		t.Fatalf("Unexpected unexported field %+v", secret)
+	}
+
+	v = autorefresh.Inspect(struct {
+		List []int
+		Text string
+		Deep [][][]int
+	}{List: make([]int, 10), Text: strings.Repeat("x", 20), Deep: [][][]int{{{1}}}}, autorefresh.InspectLimits{Depth: 2, Items: 3, String: 5})
+	if list := v.Fields[0].Value; list.Len != 10 || len(list.Fields) != 3 || list.Truncated != 7 {
+		t.Fatalf("Expected the list to be truncated, got %+v", list)
+	}
+	if text := v.Fields[1].Value; text.Value != `"xxxxx"` || text.Truncated != 15 {
+		t.Fatalf("Expected the text to be truncated, got %+v", text)
+	}
+	if deep := v.Fields[2].Value.Fields[0].Value; deep.Fields != nil || deep.Truncated != 1 {
+		t.Fatalf("Expected nesting to be limited, got %+v", deep)
+	}
+}
@@ -0,0 +1,161 @@
+// Package diff compares texts line by line.
+package diff
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is a line of a diff. Equal edits hold the line in both A and B, Delete edits only in A and
+// Insert edits only in B.
+type Edit struct {
+	Op Op
+	A  string
+	B  string
+}
+
+// maxCells bounds the size of the table used to find the longest common subsequence, which takes
+// 4 bytes per cell. Beyond it, the texts are split at the lines they have in common that appear only
+// once in each, and the parts are compared separately. Parts still too large without such lines are
+// reported as deleted and inserted as a whole.
+const maxCells = 1 << 18
+
+// Lines returns the edits turning the lines a into the lines b, keeping as many lines equal as
+// possible. Deletions are listed before the insertions replacing them.
+func Lines(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var edits []Edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, A: line, B: line})
+	}
+	edits = append(edits, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, A: line, B: line})
+	}
+	return edits
+}
+
+func middle(a, b []string) []Edit {
+	var edits []Edit
+	if len(a)*len(b) > maxCells {
+		anchors := uniqueAnchors(a, b)
+		if len(anchors) == 0 {
+			for _, line := range a {
+				edits = append(edits, Edit{Op: Delete, A: line})
+			}
+			for _, line := range b {
+				edits = append(edits, Edit{Op: Insert, B: line})
+			}
+			return edits
+		}
+		i, j := 0, 0
+		for _, anchor := range anchors {
+			edits = append(edits, Lines(a[i:anchor.a], b[j:anchor.b])...)
+			edits = append(edits, Edit{Op: Equal, A: a[anchor.a], B: b[anchor.b]})
+			i, j = anchor.a+1, anchor.b+1
+		}
+		return append(edits, Lines(a[i:], b[j:])...)
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	cells := make([]int32, (len(a)+1)*(len(b)+1))
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = cells[i*(len(b)+1) : (i+1)*(len(b)+1)]
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, Edit{Op: Equal, A: a[i], B: b[j]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, Edit{Op: Delete, A: a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: Insert, B: b[j]})
+			j++
+		}
+	}
+	return edits
+}
+
+type anchor struct{ a, b int }
+
+// uniqueAnchors returns the longest sequence of line pairs, in order in both a and b, whose line
+// appears exactly once in each.
+func uniqueAnchors(a, b []string) []anchor {
+	counts := map[string][2]int{}
+	for _, line := range a {
+		c := counts[line]
+		c[0]++
+		counts[line] = c
+	}
+	indexB := map[string]int{}
+	for j, line := range b {
+		c := counts[line]
+		c[1]++
+		counts[line] = c
+		indexB[line] = j
+	}
+	var pairs []anchor
+	for i, line := range a {
+		if c := counts[line]; c[0] == 1 && c[1] == 1 {
+			pairs = append(pairs, anchor{a: i, b: indexB[line]})
+		}
+	}
+	// Longest increasing subsequence of the pairs by b: tails[k] is the index of the pair ending the
+	// best sequence of length k+1 found so far, and prev links each pair to the one before it.
+	var tails []int
+	prev := make([]int, len(pairs))
+	for k, pair := range pairs {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if pairs[tails[mid]].b < pair.b {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[k] = -1
+		if lo > 0 {
+			prev[k] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, k)
+		} else {
+			tails[lo] = k
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	anchors := make([]anchor, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		anchors[i] = pairs[k]
+	}
+	return anchors
+}
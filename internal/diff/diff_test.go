@@ -0,0 +1,52 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+	a := strings.Split("<ul>\n<li>a</li>\n<li>b</li>\n</ul>", "\n")
+	b := strings.Split("<ul>\n<li>a</li>\n<li>c</li>\n<li>d</li>\n</ul>", "\n")
+	expected := []Edit{
+		{Op: Equal, A: "<ul>", B: "<ul>"},
+		{Op: Equal, A: "<li>a</li>", B: "<li>a</li>"},
+		{Op: Delete, A: "<li>b</li>"},
+		{Op: Insert, B: "<li>c</li>"},
+		{Op: Insert, B: "<li>d</li>"},
+		{Op: Equal, A: "</ul>", B: "</ul>"},
+	}
+	if got := Lines(a, b); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	if got := Lines(nil, []string{"x"}); !reflect.DeepEqual(got, []Edit{{Op: Insert, B: "x"}}) {
+		t.Fatalf("Unexpected edits %v", got)
+	}
+}
+
+func TestLinesBeyondTable(t *testing.T) {
+	t.Parallel()
+	// Pages too large for the table, changed at both ends so the common prefix and suffix do not
+	// shrink them, with repeated lines between the unique ones.
+	var a, b []string
+	for i := 0; i < 1000; i++ {
+		a = append(a, fmt.Sprintf("<li>%d</li>", i), "<br>")
+		b = append(b, fmt.Sprintf("<li>%d</li>", i), "<br>")
+	}
+	a[0], b[0] = "<title>Before</title>", "<title>After</title>"
+	b[1000] = "<li>changed</li>"
+	a[len(a)-1], b[len(b)-1] = "<!-- a -->", "<!-- b -->"
+
+	changed := 0
+	for _, e := range Lines(a, b) {
+		if e.Op != Equal {
+			changed++
+		}
+	}
+	if changed != 6 {
+		t.Fatalf("Expected the 3 changed lines as 6 edits, got %d", changed)
+	}
+}
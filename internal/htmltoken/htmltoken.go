@@ -0,0 +1,224 @@
+// Package htmltoken splits HTML documents into tokens. It is lenient like browsers are, and only
+// recognizes what the development tools need: tags with their attributes, text, comments and
+// doctypes. The contents of raw text elements, such as script and style, are returned as text.
+package htmltoken
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// Kind is the kind of a Token.
+type Kind int
+
+const (
+	Text Kind = iota
+	StartTag
+	EndTag
+	Comment
+	Doctype
+)
+
+// Attr is an attribute of a tag, with its value unescaped.
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Token is a piece of an HTML document.
+type Token struct {
+	Kind Kind
+	// Name is the lower case name of a tag.
+	Name  string
+	Attrs []Attr
+	// SelfClosing is set for start tags ending with "/>".
+	SelfClosing bool
+	// Data is the unescaped content of text, and the content of comments and doctypes.
+	Data string
+	// Line is the line the token starts on, counting from 1.
+	Line int
+}
+
+// Attr returns the value of the attribute name of a tag, and whether it has the attribute.
+func (t Token) Attr(name string) (string, bool) {
+	for _, a := range t.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// rawText are the elements whose content is not parsed as HTML.
+var rawText = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// Tokenize splits src into tokens.
+func Tokenize(src []byte) []Token {
+	t := tokenizer{src: src, line: 1}
+	for t.pos < len(t.src) {
+		t.next()
+	}
+	return t.tokens
+}
+
+type tokenizer struct {
+	src    []byte
+	pos    int
+	line   int
+	tokens []Token
+}
+
+// advance moves to position to, counting the lines passed.
+func (t *tokenizer) advance(to int) {
+	t.line += bytes.Count(t.src[t.pos:to], []byte("\n"))
+	t.pos = to
+}
+
+func (t *tokenizer) emit(token Token, end int) {
+	token.Line = t.line
+	t.tokens = append(t.tokens, token)
+	t.advance(end)
+}
+
+func (t *tokenizer) next() {
+	rest := t.src[t.pos:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("<!--")):
+		end := bytes.Index(rest[4:], []byte("-->"))
+		if end < 0 {
+			t.emit(Token{Kind: Comment, Data: string(rest[4:])}, len(t.src))
+			return
+		}
+		t.emit(Token{Kind: Comment, Data: string(rest[4 : 4+end])}, t.pos+4+end+3)
+	case bytes.HasPrefix(rest, []byte("<!")) || bytes.HasPrefix(rest, []byte("<?")):
+		end, next := tagEnd(rest)
+		kind := Comment
+		if len(rest) > 9 && strings.EqualFold(string(rest[2:9]), "doctype") {
+			kind = Doctype
+		}
+		data := ""
+		if end > 2 {
+			data = strings.TrimSpace(string(rest[2:end]))
+		}
+		t.emit(Token{Kind: kind, Data: data}, t.pos+next)
+	case len(rest) > 2 && rest[0] == '<' && rest[1] == '/' && isLetter(rest[2]):
+		end, next := tagEnd(rest)
+		name := rest[2:end]
+		if i := bytes.IndexAny(name, " \t\r\n\f/"); i >= 0 {
+			name = name[:i]
+		}
+		t.emit(Token{Kind: EndTag, Name: strings.ToLower(string(name))}, t.pos+next)
+	case len(rest) > 1 && rest[0] == '<' && isLetter(rest[1]):
+		t.startTag()
+	default:
+		end := bytes.IndexByte(rest[1:], '<')
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end++
+		}
+		t.emit(Token{Kind: Text, Data: html.UnescapeString(string(rest[:end]))}, t.pos+end)
+	}
+}
+
+// tagEnd returns the position of the ">" ending the tag rest starts with, and the position after it.
+// Tags cut short by the end of the document end with it.
+func tagEnd(rest []byte) (end, next int) {
+	end = bytes.IndexByte(rest, '>')
+	if end < 0 {
+		return len(rest), len(rest)
+	}
+	return end, end + 1
+}
+
+func (t *tokenizer) startTag() {
+	i := t.pos + 1
+	for i < len(t.src) && !isSpace(t.src[i]) && t.src[i] != '>' && t.src[i] != '/' {
+		i++
+	}
+	token := Token{Kind: StartTag, Name: strings.ToLower(string(t.src[t.pos+1 : i]))}
+	for i < len(t.src) {
+		for i < len(t.src) && isSpace(t.src[i]) {
+			i++
+		}
+		if i >= len(t.src) {
+			break
+		}
+		if t.src[i] == '>' {
+			i++
+			break
+		}
+		if t.src[i] == '/' {
+			i++
+			if i < len(t.src) && t.src[i] == '>' {
+				token.SelfClosing = true
+				i++
+				break
+			}
+			continue
+		}
+		start := i
+		for i < len(t.src) && !isSpace(t.src[i]) && t.src[i] != '>' && t.src[i] != '/' && (t.src[i] != '=' || i == start) {
+			i++
+		}
+		attr := Attr{Name: strings.ToLower(string(t.src[start:i]))}
+		j := i
+		for j < len(t.src) && isSpace(t.src[j]) {
+			j++
+		}
+		if j < len(t.src) && t.src[j] == '=' {
+			i = j + 1
+			for i < len(t.src) && isSpace(t.src[i]) {
+				i++
+			}
+			if i < len(t.src) && (t.src[i] == '"' || t.src[i] == '\'') {
+				quote := t.src[i]
+				end := bytes.IndexByte(t.src[i+1:], quote)
+				if end < 0 {
+					end = len(t.src) - i - 1
+				}
+				attr.Value = html.UnescapeString(string(t.src[i+1 : i+1+end]))
+				i += end + 2
+			} else {
+				start := i
+				for i < len(t.src) && !isSpace(t.src[i]) && t.src[i] != '>' {
+					i++
+				}
+				attr.Value = html.UnescapeString(string(t.src[start:i]))
+			}
+		}
+		token.Attrs = append(token.Attrs, attr)
+	}
+	if i > len(t.src) {
+		i = len(t.src)
+	}
+	t.emit(token, i)
+	if rawText[token.Name] && !token.SelfClosing {
+		t.rawText(token.Name)
+	}
+}
+
+// rawText emits the content of a raw text element as a single text token.
+func (t *tokenizer) rawText(name string) {
+	lower := bytes.ToLower(t.src[t.pos:])
+	end := bytes.Index(lower, []byte("</"+name))
+	if end < 0 {
+		end = len(lower)
+	}
+	if end > 0 {
+		data := string(t.src[t.pos : t.pos+end])
+		if name == "textarea" || name == "title" {
+			data = html.UnescapeString(data)
+		}
+		t.emit(Token{Kind: Text, Data: data}, t.pos+end)
+	}
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,53 @@
+package htmltoken
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+	src := "<!DOCTYPE html>\n<html lang=en><!-- c -->\n<a href=\"/a?x=1&amp;y=2\" data-x='q' hidden>A &amp; B</a><br/>\n<script>if (a < b) {}</script></HTML>"
+	expected := []Token{
+		{Kind: Doctype, Data: "DOCTYPE html", Line: 1},
+		{Kind: Text, Data: "\n", Line: 1},
+		{Kind: StartTag, Name: "html", Attrs: []Attr{{"lang", "en"}}, Line: 2},
+		{Kind: Comment, Data: " c ", Line: 2},
+		{Kind: Text, Data: "\n", Line: 2},
+		{Kind: StartTag, Name: "a", Attrs: []Attr{{"href", "/a?x=1&y=2"}, {"data-x", "q"}, {"hidden", ""}}, Line: 3},
+		{Kind: Text, Data: "A & B", Line: 3},
+		{Kind: EndTag, Name: "a", Line: 3},
+		{Kind: StartTag, Name: "br", SelfClosing: true, Line: 3},
+		{Kind: Text, Data: "\n", Line: 3},
+		{Kind: StartTag, Name: "script", Line: 4},
+		{Kind: Text, Data: "if (a < b) {}", Line: 4},
+		{Kind: EndTag, Name: "script", Line: 4},
+		{Kind: EndTag, Name: "html", Line: 4},
+	}
+	got := Tokenize([]byte(src))
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected\n%+v\ngot\n%+v", expected, got)
+	}
+	if href, ok := got[5].Attr("href"); !ok || href != "/a?x=1&y=2" {
+		t.Fatalf("Unexpected href %q", href)
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	t.Parallel()
+	for _, src := range []string{"<a href=\"x", "<!-- open", "</p", "<p a=", "a < b"} {
+		// Must not panic, and must make progress.
+		_ = Tokenize([]byte(src))
+	}
+	for src, expected := range map[string]Token{
+		"<p>x</p><!":   {Kind: Comment, Line: 1},
+		"<p>x</p><?":   {Kind: Comment, Line: 1},
+		"<p>x</p><!do": {Kind: Comment, Data: "do", Line: 1},
+		"<p>x</p></x":  {Kind: EndTag, Name: "x", Line: 1},
+	} {
+		tokens := Tokenize([]byte(src))
+		if len(tokens) != 4 || !reflect.DeepEqual(tokens[3], expected) {
+			t.Fatalf("Expected %q to end with %+v, got %+v", src, expected, tokens)
+		}
+	}
+}
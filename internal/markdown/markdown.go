@@ -0,0 +1,376 @@
+// Package markdown renders the commonly used subset of Markdown to HTML: headings, paragraphs,
+// emphasis, links, images, code spans and fenced code blocks, block quotes, lists, horizontal rules
+// and GitHub-style tables. Raw HTML in the source is escaped rather than passed through.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Render converts Markdown source to HTML.
+func Render(src []byte) []byte {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	var b bytes.Buffer
+	renderBlocks(&b, strings.Split(text, "\n"))
+	return b.Bytes()
+}
+
+// Title returns the text of the first heading in src, or "" when there is none.
+func Title(src []byte) string {
+	for _, line := range strings.Split(string(src), "\n") {
+		if level, text := heading(line); level > 0 {
+			return text
+		}
+	}
+	return ""
+}
+
+var (
+	orderedItem   = regexp.MustCompile(`^( {0,3})(\d{1,9})[.)]( +|$)`)
+	unorderedItem = regexp.MustCompile(`^( {0,3})([-*+])( +|$)`)
+	tableDivider  = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	nonSlug       = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func renderBlocks(b *bytes.Buffer, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			i = renderFence(b, lines, i)
+		case isRule(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case orderedItem.MatchString(line) || unorderedItem.MatchString(line):
+			i = renderList(b, lines, i)
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDivider.MatchString(strings.TrimSpace(lines[i+1])):
+			i = renderTable(b, lines, i)
+		default:
+			if level, text := heading(line); level > 0 {
+				fmt.Fprintf(b, "<h%d id=\"%s\">%s</h%d>\n", level, slug(text), inline(text), level)
+				i++
+				continue
+			}
+			var paragraph []string
+			for ; i < len(lines) && startsParagraphLine(lines, i, len(paragraph) == 0); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			fmt.Fprintf(b, "<p>%s</p>\n", inline(strings.Join(paragraph, "\n")))
+		}
+	}
+}
+
+// startsParagraphLine reports whether lines[i] continues the current paragraph.
+func startsParagraphLine(lines []string, i int, first bool) bool {
+	if first {
+		return true
+	}
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || isRule(trimmed) || strings.HasPrefix(trimmed, ">") ||
+		strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") ||
+		orderedItem.MatchString(line) || unorderedItem.MatchString(line) {
+		return false
+	}
+	level, _ := heading(line)
+	return level == 0
+}
+
+func heading(line string) (int, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(trimmed) && trimmed[level] != ' ') {
+		return 0, ""
+	}
+	text := strings.TrimSpace(trimmed[level:])
+	text = strings.TrimSpace(strings.TrimRight(text, "#"))
+	return level, text
+}
+
+func isRule(trimmed string) bool {
+	compact := strings.ReplaceAll(trimmed, " ", "")
+	if len(compact) < 3 {
+		return false
+	}
+	for _, c := range []string{"-", "*", "_"} {
+		if strings.Trim(compact, c) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func slug(text string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+func renderFence(b *bytes.Buffer, lines []string, i int) int {
+	open := strings.TrimSpace(lines[i])
+	marker := open[:3]
+	lang := strings.TrimSpace(strings.TrimLeft(open, marker[:1]))
+	if lang != "" {
+		fmt.Fprintf(b, "<pre><code class=\"language-%s\">", html.EscapeString(strings.Fields(lang)[0]))
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), marker) {
+			i++
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]))
+		b.WriteByte('\n')
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderList(b *bytes.Buffer, lines []string, i int) int {
+	ordered := orderedItem.MatchString(lines[i])
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	if m := orderedItem.FindStringSubmatch(lines[i]); ordered && m[2] != "1" {
+		fmt.Fprintf(b, "<ol start=\"%s\">\n", strings.TrimLeft(m[2], "0"))
+	} else {
+		fmt.Fprintf(b, "<%s>\n", tag)
+	}
+	for i < len(lines) {
+		m := unorderedItem.FindStringSubmatch(lines[i])
+		if ordered {
+			m = orderedItem.FindStringSubmatch(lines[i])
+		}
+		if m == nil {
+			break
+		}
+		indent := len(m[0])
+		item := []string{lines[i][indent:]}
+		loose := false
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line only continues the item when the next line is indented.
+				if i+1 < len(lines) && leadingSpaces(lines[i+1]) >= indent && strings.TrimSpace(lines[i+1]) != "" {
+					loose = true
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) >= indent {
+				item = append(item, line[indent:])
+				continue
+			}
+			if leadingSpaces(line) >= 2 && (orderedItem.MatchString(strings.TrimLeft(line, " ")) || unorderedItem.MatchString(strings.TrimLeft(line, " "))) {
+				item = append(item, strings.TrimLeft(line, " "))
+				continue
+			}
+			if orderedItem.MatchString(line) || unorderedItem.MatchString(line) || !startsParagraphLine(lines, i, false) {
+				break
+			}
+			// Lazy continuation of the item's paragraph.
+			item = append(item, strings.TrimSpace(line))
+		}
+		b.WriteString("<li>")
+		renderItem(b, item, loose)
+		b.WriteString("</li>\n")
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) &&
+			(orderedItem.MatchString(lines[i+1]) || unorderedItem.MatchString(lines[i+1])) {
+			i++
+		}
+	}
+	fmt.Fprintf(b, "</%s>\n", tag)
+	return i
+}
+
+// renderItem renders the content of a list item, without paragraph tags around the text of tight items.
+func renderItem(b *bytes.Buffer, item []string, loose bool) {
+	if loose {
+		b.WriteByte('\n')
+		renderBlocks(b, item)
+		return
+	}
+	end := 0
+	for end < len(item) && startsParagraphLine(item, end, end == 0) {
+		end++
+	}
+	b.WriteString(inline(strings.TrimSpace(strings.Join(item[:end], "\n"))))
+	if end < len(item) {
+		b.WriteByte('\n')
+		renderBlocks(b, item[end:])
+	}
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func renderTable(b *bytes.Buffer, lines []string, i int) int {
+	header := tableCells(lines[i])
+	var aligns []string
+	for _, cell := range tableCells(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			if j < len(aligns) && aligns[j] != "" {
+				fmt.Fprintf(b, "<%s style=\"text-align:%s\">%s</%s>", tag, aligns[j], inline(cell), tag)
+			} else {
+				fmt.Fprintf(b, "<%s>%s</%s>", tag, inline(cell), tag)
+			}
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("<table>\n<thead>\n")
+	row(header, "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+		row(tableCells(lines[i]), "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	var cells []string
+	var cell strings.Builder
+	for j := 0; j < len(line); j++ {
+		switch {
+		case line[j] == '\\' && j+1 < len(line) && line[j+1] == '|':
+			cell.WriteByte('|')
+			j++
+		case line[j] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[j])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+var (
+	codeSpan = regexp.MustCompile("(`+)(.+?)(`+)")
+	image    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+&#34;([^&]*)&#34;)?\)`)
+	link     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+&#34;([^&]*)&#34;)?\)`)
+	autolink = regexp.MustCompile(`&lt;(https?://[^\s&]+)&gt;`)
+	strong   = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	em       = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)?)[*_]`)
+	strike   = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+)
+
+// escapable are the characters that can be escaped with a backslash.
+const escapable = "\\`*_{}[]()#+-.!|~<>\""
+
+// inline renders the inline elements of text. Code spans and escaped characters are replaced by
+// placeholders first so the other elements are not recognised inside them.
+func inline(text string) string {
+	var placeholders []string
+	hold := func(s string) string {
+		placeholders = append(placeholders, s)
+		return fmt.Sprintf("\x00%d\x00", len(placeholders)-1)
+	}
+	var escaped strings.Builder
+	for j := 0; j < len(text); j++ {
+		if text[j] == '\\' && j+1 < len(text) && strings.IndexByte(escapable, text[j+1]) >= 0 {
+			escaped.WriteString(hold(html.EscapeString(text[j+1 : j+2])))
+			j++
+			continue
+		}
+		escaped.WriteByte(text[j])
+	}
+	text = codeSpan.ReplaceAllStringFunc(escaped.String(), func(s string) string {
+		m := codeSpan.FindStringSubmatch(s)
+		if m[1] != m[3] {
+			return s
+		}
+		return hold("<code>" + html.EscapeString(strings.TrimSpace(m[2])) + "</code>")
+	})
+	text = html.EscapeString(text)
+	text = image.ReplaceAllStringFunc(text, func(s string) string {
+		m := image.FindStringSubmatch(s)
+		return hold(fmt.Sprintf("<img src=\"%s\" alt=\"%s\"%s>", safeURL(m[2]), m[1], title(m[3])))
+	})
+	// Links are held, so emphasis only applies to their text and not to their URL.
+	text = link.ReplaceAllStringFunc(text, func(s string) string {
+		m := link.FindStringSubmatch(s)
+		return hold(fmt.Sprintf("<a href=\"%s\"%s>%s</a>", safeURL(m[2]), title(m[3]), emphasize(m[1])))
+	})
+	text = autolink.ReplaceAllStringFunc(text, func(s string) string {
+		return hold(autolink.ReplaceAllString(s, `<a href="$1">$1</a>`))
+	})
+	text = emphasize(text)
+	text = strings.ReplaceAll(text, "  \n", "<br>\n")
+	for j := len(placeholders) - 1; j >= 0; j-- {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00%d\x00", j), placeholders[j])
+	}
+	return text
+}
+
+// emphasize renders strong, emphasized and struck through text.
+func emphasize(text string) string {
+	text = strong.ReplaceAllString(text, "<strong>$2</strong>")
+	text = em.ReplaceAllString(text, "$1<em>$2</em>")
+	return strike.ReplaceAllString(text, "<del>$1</del>")
+}
+
+func title(t string) string {
+	if t == "" {
+		return ""
+	}
+	return fmt.Sprintf(" title=\"%s\"", t)
+}
+
+// safeURL drops javascript: and similar URLs, the URL is already HTML escaped.
+func safeURL(u string) string {
+	lower := strings.ToLower(u)
+	if i := strings.IndexByte(lower, ':'); i >= 0 && !strings.ContainsAny(lower[:i], "/?#") {
+		switch lower[:i] {
+		case "http", "https", "mailto":
+		default:
+			return "#"
+		}
+	}
+	return u
+}
@@ -0,0 +1,40 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"heading", "# Hello *World*", "<h1 id=\"hello-world\">Hello <em>World</em></h1>\n"},
+		{"paragraph", "some **bold**\nand `<code>`", "<p>some <strong>bold</strong>\nand <code>&lt;code&gt;</code></p>\n"},
+		{"escaped html", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"link", "[docs](https://example.com \"Docs\") and [bad](javascript:alert)", "<p><a href=\"https://example.com\" title=\"Docs\">docs</a> and <a href=\"#\">bad</a></p>\n"},
+		{"underscores in href", "[init](pkg/__init__.py) and [d](/docs/_drafts/_a.md)", "<p><a href=\"pkg/__init__.py\">init</a> and <a href=\"/docs/_drafts/_a.md\">d</a></p>\n"},
+		{"emphasis in link", "_see [the **docs**](/a_b_c)_ <https://example.com/__x__>", "<p><em>see <a href=\"/a_b_c\">the <strong>docs</strong></a></em> <a href=\"https://example.com/__x__\">https://example.com/__x__</a></p>\n"},
+		{"fence", "```go\nif a < b {\n```", "<pre><code class=\"language-go\">if a &lt; b {\n</code></pre>\n"},
+		{"list", "- one\n- two\n  - nested\n\n1. first", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>\n"},
+		{"quote", "> quoted\n> text", "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n"},
+		{"rule", "a\n\n---", "<p>a</p>\n<hr>\n"},
+		{
+			"table",
+			"| Name | Size |\n|:-----|-----:|\n| a \\| b | `1` |",
+			"<table>\n<thead>\n<tr><th style=\"text-align:left\">Name</th><th style=\"text-align:right\">Size</th></tr>\n</thead>\n<tbody>\n<tr><td style=\"text-align:left\">a | b</td><td style=\"text-align:right\"><code>1</code></td></tr>\n</tbody>\n</table>\n",
+		},
+	}
+	for _, c := range cases {
+		if got := string(Render([]byte(c.src))); got != c.expected {
+			t.Errorf("%s: expected\n%q\ngot\n%q", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	t.Parallel()
+	if title := Title([]byte("intro\n\n## Design doc ##\n# Other")); title != "Design doc" {
+		t.Fatalf("Unexpected title %q", title)
+	}
+}
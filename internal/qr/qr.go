@@ -0,0 +1,496 @@
+// Package qr encodes short strings, such as URLs, as QR codes and renders them for terminals.
+//
+// Only what the development tools need is supported: byte mode, error correction level M and
+// versions 1 to 10, which fits up to 213 bytes.
+package qr
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrTooLong = errors.New("Data is too long for a QR code")
+
+// quietZone is the number of light modules around the code scanners need to find it.
+const quietZone = 4
+
+// block describes the error correction blocks of a version at level M.
+type block struct {
+	ecPerBlock int
+	// blocks and dataPerBlock describe the first group of blocks, longBlocks the second group,
+	// whose blocks hold one data codeword more.
+	blocks       int
+	dataPerBlock int
+	longBlocks   int
+}
+
+var versions = [...]block{
+	1:  {10, 1, 16, 0},
+	2:  {16, 1, 28, 0},
+	3:  {26, 1, 44, 0},
+	4:  {18, 2, 32, 0},
+	5:  {24, 2, 43, 0},
+	6:  {16, 4, 27, 0},
+	7:  {18, 4, 31, 0},
+	8:  {22, 2, 38, 2},
+	9:  {22, 3, 36, 2},
+	10: {26, 4, 43, 1},
+}
+
+var alignmentPositions = [...][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+func (b block) dataCodewords() int {
+	return b.blocks*b.dataPerBlock + b.longBlocks*(b.dataPerBlock+1)
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	// Size is the number of modules on each side, without the quiet zone.
+	Size     int
+	version  int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode encodes data as a QR code of the smallest version it fits in.
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= versions[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+	c := &Code{Size: version*4 + 17, version: version}
+	c.modules = make([][]bool, c.Size)
+	c.function = make([][]bool, c.Size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, c.Size)
+		c.function[y] = make([]bool, c.Size)
+	}
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(version, dataCodewords(version, data)))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c, nil
+}
+
+// Dark reports whether the module in column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// WriteTerminal draws the code to w with half block characters, two rows of modules per line. The
+// colors are set explicitly, so the code stays dark on light regardless of the terminal's theme.
+func (c *Code) WriteTerminal(w io.Writer) error {
+	dark := func(x, y int) bool {
+		x, y = x-quietZone, y-quietZone
+		return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+	}
+	var b strings.Builder
+	size := c.Size + quietZone*2
+	for y := 0; y < size; y += 2 {
+		b.WriteString("\x1b[97;40m")
+		for x := 0; x < size; x++ {
+			// Rows past the bottom of the quiet zone are drawn light.
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case !top && !bottom:
+				b.WriteString("█")
+			case !top:
+				b.WriteString("▀")
+			case !bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\x1b[0m\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dataCodewords encodes data in byte mode and pads it to the capacity of version.
+func dataCodewords(version int, data []byte) []byte {
+	capacity := versions[version].dataCodewords()
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	terminator := capacity*8 - bits.len()
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	if r := bits.len() % 8; r != 0 {
+		bits.append(0, 8-r)
+	}
+	for pad := 0xEC; bits.len() < capacity*8; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes()
+}
+
+// interleave splits the data codewords into blocks, computes their error correction codewords and
+// interleaves both as the symbol requires.
+func interleave(version int, data []byte) []byte {
+	v := versions[version]
+	generator := rsGenerator(v.ecPerBlock)
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < v.blocks+v.longBlocks; i++ {
+		n := v.dataPerBlock
+		if i >= v.blocks {
+			n++
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:n], generator))
+		data = data[n:]
+	}
+	var result []byte
+	for i := 0; i <= v.dataPerBlock; i++ {
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				result = append(result, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			result = append(result, b[i])
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+	positions := alignmentPositions[c.version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Alignment patterns overlapping the finder patterns are left out.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, abs(dx) == 2 || abs(dy) == 2 || (dx == 0 && dy == 0))
+				}
+			}
+		}
+	}
+	// Reserve the format areas until the mask is chosen.
+	c.drawFormat(0)
+	if c.version >= 7 {
+		rem := c.version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := c.version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern centered on x, y with its separator.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := abs(dx)
+			if abs(dy) > d {
+				d = abs(dy)
+			}
+			c.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// formatBits returns the 15 bit format information for level M and mask.
+func formatBits(mask int) int {
+	// Level M is encoded as 00.
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawCodewords places the codewords in the two module wide columns zigzagging up and down from
+// the bottom right corner, skipping the function patterns.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				c.modules[y][x] = (codewords[i>>3]>>(7-(i&7)))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask. Applying the same mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, following the rules of the specification used to
+// pick the mask.
+func (c *Code) penalty() int {
+	penalty := 0
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := 0; j < c.Size; j++ {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				m := c.modules[y][x]
+				if m == c.modules[y-1][x] && m == c.modules[y][x-1] && m == c.modules[y-1][x-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	penalty += abs(dark*100/total-50) / 5 * 10
+	return penalty
+}
+
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += 3 + run - 5
+		}
+		run = 1
+	}
+	for i := 0; i+len(finderLike[0]) <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				penalty += 40
+			}
+		}
+	}
+	return penalty
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+type bitBuffer struct {
+	data []byte
+	n    int
+}
+
+func (b *bitBuffer) append(value, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.data = append(b.data, 0)
+		}
+		if (value>>i)&1 == 1 {
+			b.data[b.n/8] |= 1 << (7 - b.n%8)
+		}
+		b.n++
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return b.n
+}
+
+func (b *bitBuffer) bytes() []byte {
+	return b.data
+}
+
+// rsGenerator returns the coefficients of the Reed-Solomon generator polynomial of degree, highest
+// first and without the leading 1.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range generator {
+			result[i] ^= gfMul(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= ((int(y) >> i) & 1) * int(x)
+	}
+	return byte(z)
+}
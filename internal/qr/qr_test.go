@@ -0,0 +1,124 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	t.Parallel()
+	// The "HELLO WORLD" example at version 1-M from the specification.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(10)); !bytes.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	t.Parallel()
+	for mask, expected := range map[int]int{0: 0b101010000010010, 5: 0b100000011001110, 7: 0b100101010100000} {
+		if got := formatBits(mask); got != expected {
+			t.Errorf("Mask %d: expected %015b, got %015b", mask, expected, got)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{"http://192.168.1.20:8080", strings.Repeat("https://example.com/", 9)} {
+		c, err := Encode([]byte(s))
+		if err != nil {
+			t.Fatalf("Could not encode %q. %v", s, err)
+		}
+		symbol := make([][]bool, c.Size)
+		for y := range symbol {
+			symbol[y] = append([]bool(nil), c.modules[y]...)
+		}
+		mask := -1
+		for m := 0; m < 8; m++ {
+			c.drawFormat(m)
+			if equal(c.modules, symbol) {
+				mask = m
+			}
+		}
+		if mask < 0 {
+			t.Fatalf("%q: no mask matches the format information", s)
+		}
+		c.applyMask(mask)
+		var read []byte
+		i := 0
+		for right := c.Size - 1; right >= 1; right -= 2 {
+			if right == 6 {
+				right = 5
+			}
+			for vert := 0; vert < c.Size; vert++ {
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				for j := 0; j < 2; j++ {
+					if c.function[y][right-j] {
+						continue
+					}
+					if i%8 == 0 {
+						read = append(read, 0)
+					}
+					if c.modules[y][right-j] {
+						read[i/8] |= 1 << (7 - i%8)
+					}
+					i++
+				}
+			}
+		}
+		expected := interleave(c.version, dataCodewords(c.version, []byte(s)))
+		remainder := 0
+		if c.version >= 2 && c.version <= 6 {
+			remainder = 7
+		}
+		if i != len(expected)*8+remainder {
+			t.Fatalf("%q: expected %d data modules, got %d", s, len(expected)*8+remainder, i)
+		}
+		if !bytes.HasPrefix(read, expected) {
+			t.Fatalf("%q: codewords were not placed as expected", s)
+		}
+	}
+}
+
+func equal(a, b [][]bool) bool {
+	for y := range a {
+		for x := range a[y] {
+			if a[y][x] != b[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	t.Parallel()
+	if _, err := Encode(make([]byte, 214)); err != ErrTooLong {
+		t.Fatalf("Expected ErrTooLong, got %v", err)
+	}
+	if c, err := Encode(make([]byte, 213)); err != nil || c.Size != 57 {
+		t.Fatalf("Expected a version 10 code, got %v", err)
+	}
+}
+
+func TestWriteTerminal(t *testing.T) {
+	t.Parallel()
+	c, err := Encode([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := c.WriteTerminal(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != (c.Size+quietZone*2+1)/2 {
+		t.Fatalf("Unexpected number of lines %d", len(lines))
+	}
+}
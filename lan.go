@@ -0,0 +1,83 @@
+package autorefresh
+
+import (
+	"fmt"
+	"net"
+)
+
+// LANAddresses returns the IPv4 addresses of the network interfaces that are up, leaving out
+// loopback and link-local addresses, so other devices on the network can reach this machine on them.
+func LANAddresses() ([]net.IP, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// LANURLs returns the URLs a server listening on addr is reachable at from other devices on the
+// network. The scheme is https when secure is set and http otherwise.
+func LANURLs(addr string, secure bool) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidParameters, err)
+	}
+	ips, err := LANAddresses()
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	var urls []string
+	for _, ip := range ips {
+		// A server listening on a specific address is only reachable on that address.
+		if host != "" && host != "0.0.0.0" && host != "::" && host != ip.String() {
+			continue
+		}
+		urls = append(urls, scheme+"://"+net.JoinHostPort(ip.String(), port))
+	}
+	return urls, nil
+}
+
+// AllowLAN allows pages served from any port of this machine's LAN addresses to connect to the
+// socket, for pages opened on other devices and served by another server than the socket. Like the
+// other settings, it is meant to be called before the PageReloader is served.
+func (p *PageReloader) AllowLAN() error {
+	ips, err := LANAddresses()
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		pattern := ip.String() + ":*"
+		if !contains(p.OriginPatterns, pattern) {
+			p.OriginPatterns = append(p.OriginPatterns, pattern)
+		}
+		if !contains(p.OriginPatterns, ip.String()) {
+			p.OriginPatterns = append(p.OriginPatterns, ip.String())
+		}
+	}
+	return nil
+}
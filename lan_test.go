@@ -0,0 +1,52 @@
+package autorefresh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestOriginPatterns(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	options := &websocket.DialOptions{HTTPHeader: http.Header{"Origin": {"http://192.168.1.20:3000"}}}
+
+	if socket, _, err := websocket.Dial(ctx, url, options); err == nil {
+		socket.CloseNow()
+		t.Fatal("A page of another origin was allowed to connect")
+	}
+	reloader.OriginPatterns = []string{"192.168.1.20:*"}
+	socket, _, err := websocket.Dial(ctx, url, options)
+	if err != nil {
+		t.Fatalf("A page of an allowed origin could not connect. %v", err)
+	}
+	socket.CloseNow()
+}
+
+func TestLANURLs(t *testing.T) {
+	t.Parallel()
+	if _, err := autorefresh.LANURLs("8080", false); err == nil {
+		t.Fatal("Expected an error for an address without a port")
+	}
+	urls, err := autorefresh.LANURLs("127.0.0.1:8080", false)
+	if err != nil {
+		t.Fatalf("Could not list URLs. %v", err)
+	}
+	if len(urls) != 0 {
+		t.Fatalf("A server listening on the loopback address is not reachable from the network, got %v", urls)
+	}
+}
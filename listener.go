@@ -0,0 +1,154 @@
+package autorefresh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// Environment variables used to hand a listening socket and a readiness pipe to a child process.
+const (
+	listenFDEnv = "AUTOREFRESH_LISTEN_FD"
+	readyFDEnv  = "AUTOREFRESH_READY_FD"
+)
+
+// sdListenFDsStart is the first file descriptor passed by systemd-style socket activation.
+const sdListenFDsStart = 3
+
+var ErrListenerHandoff = errors.New("Failed to hand off listener")
+
+// Listen returns the listener inherited from a supervisor when there is one, and a new listener on
+// network and addr otherwise. A listener is inherited either systemd-style through LISTEN_FDS, or
+// through the file descriptor passed by a Handoff.
+//
+// Because the supervisor keeps the socket open, connections made while the application restarts
+// wait in the socket's backlog instead of being refused.
+func Listen(network, addr string) (net.Listener, error) {
+	fd, name, ok, err := inheritedFD()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return net.Listen(network, addr)
+	}
+	f := os.NewFile(fd, name)
+	if f == nil {
+		return nil, fmt.Errorf("%w: invalid file descriptor %d", ErrListenerHandoff, fd)
+	}
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListenerHandoff, err)
+	}
+	return l, nil
+}
+
+func inheritedFD() (fd uintptr, name string, ok bool, err error) {
+	if v := os.Getenv(listenFDEnv); v != "" {
+		_ = os.Unsetenv(listenFDEnv)
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return 0, "", false, fmt.Errorf("%w: invalid %s %q", ErrListenerHandoff, listenFDEnv, v)
+		}
+		return uintptr(n), "autorefresh-listener", true, nil
+	}
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return 0, "", false, nil
+	}
+	// LISTEN_PID is optional, but when it is set the sockets are only meant for that process.
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, "", false, nil
+	}
+	if n, err := strconv.Atoi(fds); err != nil || n < 1 {
+		return 0, "", false, fmt.Errorf("%w: invalid LISTEN_FDS %q", ErrListenerHandoff, fds)
+	}
+	for _, key := range []string{"LISTEN_FDS", "LISTEN_PID", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+	return sdListenFDsStart, "systemd-listener", true, nil
+}
+
+// NotifyReady tells the supervisor that started this process through a Handoff that it is accepting
+// connections, so the previous process can be stopped. It does nothing when there is no supervisor.
+func NotifyReady() error {
+	v := os.Getenv(readyFDEnv)
+	if v == "" {
+		return nil
+	}
+	_ = os.Unsetenv(readyFDEnv)
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: invalid %s %q", ErrListenerHandoff, readyFDEnv, v)
+	}
+	f := os.NewFile(uintptr(n), "autorefresh-ready")
+	if f == nil {
+		return fmt.Errorf("%w: invalid file descriptor %d", ErrListenerHandoff, n)
+	}
+	defer f.Close()
+	_, err = f.Write([]byte("READY=1\n"))
+	return err
+}
+
+// Handoff shares a listening socket with successive child processes, so a new process accepts
+// connections on the same socket while the previous one drains.
+//
+// A typical restart starts the new process, waits for it to call NotifyReady and only then stops
+// the previous process. Stopping it closes the sockets of connected browsers, which reconnect to
+// the new process and reload.
+type Handoff struct {
+	file *os.File
+}
+
+// NewHandoff creates a Handoff for l, which must be a TCP or Unix listener.
+func NewHandoff(l net.Listener) (*Handoff, error) {
+	filer, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported listener type %T", ErrListenerHandoff, l)
+	}
+	f, err := filer.File()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListenerHandoff, err)
+	}
+	return &Handoff{file: f}, nil
+}
+
+// Start starts cmd with the listening socket and a readiness pipe. The returned channel is closed
+// once the child calls NotifyReady; it is never closed if the child exits without doing so.
+func (h *Handoff) Start(cmd *exec.Cmd) (<-chan struct{}, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListenerHandoff, err)
+	}
+	defer w.Close()
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	first := sdListenFDsStart + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, h.file, w)
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("%s=%d", listenFDEnv, first),
+		fmt.Sprintf("%s=%d", readyFDEnv, first+1),
+	)
+	if err := cmd.Start(); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	ready := make(chan struct{})
+	go func() {
+		defer r.Close()
+		buf := make([]byte, 1)
+		if n, _ := r.Read(buf); n > 0 {
+			close(ready)
+		}
+	}()
+	return ready, nil
+}
+
+// Close releases the supervisor's copy of the listening socket.
+func (h *Handoff) Close() error {
+	return h.file.Close()
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package autorefresh_test
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestListenInheritsPassedListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen. %v", err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("Could not get listener file. %v", err)
+	}
+	// Listen takes ownership of the descriptor, so pass it a copy.
+	fd, err := syscall.Dup(int(f.Fd()))
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("Could not duplicate listener file. %v", err)
+	}
+	t.Setenv("AUTOREFRESH_LISTEN_FD", strconv.Itoa(fd))
+
+	inherited, err := autorefresh.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not inherit listener. %v", err)
+	}
+	defer inherited.Close()
+	if inherited.Addr().String() != l.Addr().String() {
+		t.Fatalf("Listener was not inherited. Listening on %s instead of %s", inherited.Addr(), l.Addr())
+	}
+}
+
+func TestListenWithoutSupervisor(t *testing.T) {
+	t.Setenv("AUTOREFRESH_LISTEN_FD", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	l, err := autorefresh.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen. %v", err)
+	}
+	defer l.Close()
+	if err := autorefresh.NotifyReady(); err != nil {
+		t.Fatalf("NotifyReady should do nothing without a supervisor. %v", err)
+	}
+}
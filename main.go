@@ -4,35 +4,174 @@
 package autorefresh
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 )
 
-const Script string = `
+// Script is the default client script. Besides path and refreshRate, it calls the template functions
+// of a PageReloader, so templates parsing it without New need them from PageReloader.Funcs.
+const Script string = `{{ block "autorefresh-head" autorefreshData }}{{ end }}
 <script>
-	function setupReloadSocket(reload = false) {
-		const reloadWebsocket = new WebSocket({{ path }});
-		let doReloadNext = reload;
-		reloadWebsocket.onopen = function () {
-			if (reload === true) {
+	(function () {
+		const config = {{ autorefreshConfig }};
+		const handlers = {};
+		const autorefresh = window.autorefresh = {
+			on: function (type, handler) {
+				(handlers[type] = handlers[type] || []).push(handler);
+			},
+			emit: function (type, data) {
+				(handlers[type] || []).forEach(function (handler) {
+					handler(data);
+				});
+			},
+			send: function (type, data) {
+				if (socket !== null && socket.readyState === WebSocket.OPEN) {
+					socket.send(JSON.stringify({ type: type, data: data }));
+				}
+			},
+			reload: function () {
+				autorefresh.emit("beforereload");
 				window.location.reload();
-			} else {
-				doReloadNext = true;
-			}
-		};
-		reloadWebsocket.onerror = function onError() {
-			setTimeout(() => setupReloadSocket(doReloadNext), {{ refreshRate }});
-		};
-		reloadWebsocket.onclose = function onClose() {
-			setTimeout(() => setupReloadSocket(doReloadNext), {{ refreshRate }});
+			},
+			// receive handles a message received over the socket.
+			receive: function (message) {
+				autorefresh.emit(message.type, message.data);
+			},
+			// connect opens the socket.
+			connect: function () {
+				setupReloadSocket();
+			},
 		};
-	}
-	setupReloadSocket();
+		let socket = null;
+		autorefresh.on("reload", function () {
+			autorefresh.reload();
+		});
+
+		if (config.preserveScroll) {
+			autorefresh.on("beforereload", function () {
+				sessionStorage.setItem("autorefresh:scroll", JSON.stringify({ href: window.location.href, x: window.scrollX, y: window.scrollY }));
+			});
+			const scroll = JSON.parse(sessionStorage.getItem("autorefresh:scroll"));
+			sessionStorage.removeItem("autorefresh:scroll");
+			if (scroll !== null && scroll.href === window.location.href) {
+				window.addEventListener("load", function () {
+					window.scrollTo(scroll.x, scroll.y);
+				});
+			}
+		}
+
+		autorefresh.on("css", function (paths) {
+			document.querySelectorAll("link[rel=stylesheet]").forEach(function (link) {
+				const url = new URL(link.href, window.location.href);
+				const changed = paths.length === 0 || paths.some(function (path) {
+					return url.pathname.endsWith(path);
+				});
+				if (changed) {
+					url.searchParams.set("autorefresh", Date.now());
+					link.href = url.href;
+				}
+			});
+		});
+
+		const problems = {};
+		let overlay = null;
+		function renderProblems() {
+			if (!document.body) {
+				document.addEventListener("DOMContentLoaded", renderProblems, { once: true });
+				return;
+			}
+			if (overlay === null) {
+				overlay = document.createElement("div");
+				overlay.id = "autorefresh-overlay";
+				overlay.style.cssText = "position:fixed;left:0;right:0;bottom:0;max-height:50vh;overflow:auto;z-index:2147483647;font:13px/1.4 monospace;";
+				document.body.appendChild(overlay);
+			}
+			overlay.replaceChildren();
+			Object.keys(problems).forEach(function (key) {
+				const problem = problems[key];
+				const entry = document.createElement("div");
+				entry.style.cssText = "padding:8px 12px;color:#fff;border-top:1px solid #000;background:" + (problem.level === "warning" ? "#8a6d00" : "#a11111");
+				const dismiss = document.createElement("button");
+				dismiss.textContent = "dismiss";
+				dismiss.style.cssText = "float:right;font:inherit;";
+				dismiss.onclick = function () {
+					delete problems[key];
+					renderProblems();
+				};
+				const title = document.createElement("strong");
+				title.textContent = problem.title;
+				entry.append(dismiss, title);
+				if (problem.detail) {
+					const detail = document.createElement("pre");
+					detail.style.cssText = "margin:6px 0 0;white-space:pre-wrap;";
+					detail.textContent = problem.detail;
+					entry.appendChild(detail);
+				}
+				overlay.appendChild(entry);
+			});
+		}
+		autorefresh.on("problem", function (problem) {
+			problems[problem.key] = problem;
+			renderProblems();
+		});
+		autorefresh.on("problem-clear", function (key) {
+			delete problems[key];
+			renderProblems();
+		});
+
+		// socketURL resolves path against the page, using wss for pages served over https.
+		function socketURL(path) {
+			const url = new URL(path, window.location.href);
+			if (url.protocol === "https:") {
+				url.protocol = "wss:";
+			} else if (url.protocol === "http:") {
+				url.protocol = "ws:";
+			}
+			return url.href;
+		}
+
+		function setupReloadSocket(reload = false) {
+			const reloadWebsocket = new WebSocket(socketURL({{ path }}));
+			let doReloadNext = reload;
+			reloadWebsocket.onopen = function () {
+				// The connection is set up before reloading, since features such as HTMX and ReplayForms
+				// may refresh the page in place and keep using it.
+				doReloadNext = true;
+				socket = reloadWebsocket;
+				autorefresh.emit("open");
+				if (reload === true) {
+					autorefresh.reload();
+				}
+			};
+			reloadWebsocket.onmessage = function onMessage(event) {
+				autorefresh.receive(JSON.parse(event.data));
+			};
+			reloadWebsocket.onerror = function onError() {
+				setTimeout(() => setupReloadSocket(doReloadNext), {{ refreshRate }});
+			};
+			reloadWebsocket.onclose = function onClose() {
+				if (socket === reloadWebsocket) {
+					socket = null;
+				}
+				setTimeout(() => setupReloadSocket(doReloadNext), {{ refreshRate }});
+			};
+		}
+		{{ range autorefreshScripts }}{{ . }}
+		{{ end }}
+		{{ block "autorefresh-init" autorefreshData }}{{ end }}
+		autorefresh.connect();
+	})();
 </script>
 
 `
@@ -41,8 +180,108 @@ type PageReloader struct {
 	Template    *template.Template
 	Path        string
 	RefreshRate uint
+	// PreserveScroll restores the scroll position after the page was reloaded, which browsers do not
+	// reliably do themselves for pages whose content changed.
+	PreserveScroll bool
+	// Sync mirrors scrolling, clicks, form input and navigation between the connected browsers.
+	Sync bool
+	// ReplayForms records the last form submission of each tab, and offers to replay it instead of
+	// reloading the page it led to, when its route is wrapped in ReplaySafe. AutoReplay replays it
+	// without asking.
+	ReplayForms bool
+	AutoReplay  bool
+	// HTMX refreshes pages using htmx in place on reloads, issuing the boosted request the page was
+	// reached with again, or refreshing its HTMXFragment and hx-trigger="load" elements, instead of
+	// reloading the page and losing the state of its partial swaps.
+	HTMX bool
+	// PreserveState restores the URL hash and history.state of client-side routed applications after
+	// a reload, along with the localStorage and sessionStorage keys listed, and the state saved by the
+	// hooks the application registers with autorefresh.preserve in the page.
+	PreserveState          bool
+	PreserveLocalStorage   []string
+	PreserveSessionStorage []string
+	// CoordinateFrames makes framed pages that include the script share the socket of the top window
+	// when it includes the script as well, so they reload along with it rather than on their own. Use
+	// ReloadFrames to reload the frames without the page embedding them.
+	CoordinateFrames bool
+	// OriginPatterns lists the host patterns of other origins allowed to connect to the socket, such
+	// as "192.168.1.20:*". Pages served from the same host as the socket are always allowed.
+	OriginPatterns []string
+	// ReloadDebounce coalesces Reload calls made within this duration of each other into a single
+	// reload, sent once no further call was made for the duration. Reloads are sent immediately when zero.
+	ReloadDebounce time.Duration
+
+	mu          sync.Mutex
+	clients     map[*Client]struct{}
+	closed      bool
+	reloadTimer *time.Timer
+	// retained holds messages that are also sent to browsers connecting later, keyed by what they describe.
+	retained map[string]Message
+	handlers map[string][]MessageHandler
+	// fragments are client script fragments added by the features attached to the PageReloader.
+	fragments []template.JS
+	// observers are called with every message broadcast to the browsers.
+	observers []func(Message)
+	// routes are the views served below Path, keyed by their name.
+	routes map[string]http.Handler
+	// token is included in the client script, see scriptConfig.
+	token   string
+	actions map[string]*Action
+	plugins map[string]Plugin
+}
+
+// Message is a JSON message exchanged with connected browsers over the websocket. The Data of
+// messages received from browsers holds the raw JSON, use Decode to unmarshal it.
+type Message struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Decode unmarshals the Data of the message into v.
+func (m Message) Decode(v any) error {
+	raw, ok := m.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(m.Data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, v)
 }
 
+// MessageHandler handles a message sent by the browser connected through from. The context is
+// cancelled when that browser disconnects.
+type MessageHandler func(ctx context.Context, from *Client, m Message)
+
+// Problem is an error or warning shown in an overlay in connected browsers until it is cleared.
+type Problem struct {
+	// Key identifies the problem, reporting a problem with the same key replaces the previous one.
+	Key string `json:"key"`
+	// Level is either "error" or "warning".
+	Level  string `json:"level"`
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Client is a browser connected to the PageReloader's socket.
+type Client struct {
+	messages chan Message
+	cancel   context.CancelFunc
+}
+
+// Send sends a message to the browser, reporting false when it is not keeping up with its messages.
+func (c *Client) Send(m Message) bool {
+	select {
+	case c.messages <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+// pingInterval is how often connected sockets are pinged to detect dropped browsers.
+const pingInterval = time.Second * 2
+
 var (
 	ErrInvalidParameters = errors.New("Invalid parameters")
 	ErrTemplateParsing   = errors.New("Failed to parse template")
@@ -56,18 +295,269 @@ func New(t *template.Template, path string, refreshRate uint) (*PageReloader, er
 	if refreshRate < 100 {
 		return nil, fmt.Errorf("%w: refreshRate must be at least 100ms", ErrInvalidParameters)
 	}
-	t, err := t.Funcs(template.FuncMap{
-		"path":        func() string { return path },
-		"refreshRate": func() uint { return refreshRate },
-	}).Parse(Script)
+	p := &PageReloader{Path: path, RefreshRate: refreshRate, clients: map[*Client]struct{}{}, token: randomID()}
+	p.OnMessage("sync", p.relaySync)
+	t, err := t.Funcs(p.Funcs()).Parse(Script)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrTemplateParsing, err)
 	}
-	return &PageReloader{Path: path, Template: t, RefreshRate: refreshRate}, nil
+	p.Template = t
+	return p, nil
+}
+
+// Funcs returns the template functions Script calls, for templates parsing it that were not passed to
+// New. See ScriptData for what they return.
+func (p *PageReloader) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"path":               func() string { return p.Path },
+		"refreshRate":        func() uint { return p.RefreshRate },
+		"autorefreshConfig":  p.config,
+		"autorefreshScripts": p.scripts,
+		"autorefreshData":    p.scriptData,
+	}
+}
+
+// scriptConfig holds the settings of the client script that can be changed after New.
+type scriptConfig struct {
+	// Path is the path of the socket.
+	Path           string `json:"path"`
+	PreserveScroll bool   `json:"preserveScroll"`
+	// Token authenticates messages that make the server act, such as invoking an action. Only pages
+	// that include the script know it.
+	Token              string   `json:"token"`
+	AutoReplay         bool     `json:"autoReplay"`
+	LocalStorageKeys   []string `json:"localStorageKeys"`
+	SessionStorageKeys []string `json:"sessionStorageKeys"`
+}
+
+func (p *PageReloader) config() scriptConfig {
+	return scriptConfig{
+		Path:               p.Path,
+		PreserveScroll:     p.PreserveScroll,
+		Token:              p.token,
+		AutoReplay:         p.AutoReplay,
+		LocalStorageKeys:   p.PreserveLocalStorage,
+		SessionStorageKeys: p.PreserveSessionStorage,
+	}
+}
+
+// VerifyToken reports whether token is the one included in the client script of the pages, as
+// config.token. Handlers of messages that make the server act, such as invoking an action, should
+// require the page to send it along.
+func (p *PageReloader) VerifyToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) == 1
+}
+
+// scripts returns the client script fragments of the enabled features. They are included in the
+// client script after its core, and have access to its autorefresh and config variables.
+func (p *PageReloader) scripts() []template.JS {
+	p.mu.Lock()
+	scripts := append([]template.JS(nil), p.fragments...)
+	p.mu.Unlock()
+	if p.Sync {
+		scripts = append(scripts, syncScript)
+	}
+	if p.ReplayForms {
+		scripts = append(scripts, replayScript)
+	}
+	if p.HTMX {
+		scripts = append(scripts, htmxScript)
+	}
+	if p.PreserveState {
+		scripts = append(scripts, stateScript)
+	}
+	if p.CoordinateFrames {
+		scripts = append(scripts, framesScript)
+	}
+	return scripts
+}
+
+// Broadcast sends a message to every connected browser. Browsers that are not keeping up with
+// their messages are skipped rather than blocking the caller.
+func (p *PageReloader) Broadcast(m Message) {
+	p.broadcast(m, nil)
+}
+
+func (p *PageReloader) broadcast(m Message, except *Client) {
+	p.mu.Lock()
+	for c := range p.clients {
+		if c != except {
+			c.Send(m)
+		}
+	}
+	observers := p.observers
+	p.mu.Unlock()
+	for _, observe := range observers {
+		observe(m)
+	}
 }
 
+// addScript adds a fragment to the client script, see scripts.
+func (p *PageReloader) addScript(fragment template.JS) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fragments = append(p.fragments, fragment)
+}
+
+// observe registers a function called with every message broadcast to the browsers.
+func (p *PageReloader) observe(observer func(Message)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observers = append(p.observers, observer)
+}
+
+// OnMessage registers a handler for messages of type typ sent by browsers. Handlers are called
+// sequentially for each browser, in the order the messages were received.
+func (p *PageReloader) OnMessage(typ string, handler MessageHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.handlers == nil {
+		p.handlers = map[string][]MessageHandler{}
+	}
+	p.handlers[typ] = append(p.handlers[typ], handler)
+}
+
+func (p *PageReloader) dispatch(ctx context.Context, from *Client, m Message) {
+	p.mu.Lock()
+	handlers := p.handlers[m.Type]
+	p.mu.Unlock()
+	for _, handler := range handlers {
+		handler(ctx, from, m)
+	}
+}
+
+// Reload tells every connected browser to reload the page. This is useful when the process serving
+// the socket outlives the application, e.g. when it is served from a Proxy.
+func (p *PageReloader) Reload() {
+	if p.ReloadDebounce <= 0 {
+		p.Broadcast(Message{Type: "reload"})
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reloadTimer != nil && p.reloadTimer.Stop() {
+		p.reloadTimer.Reset(p.ReloadDebounce)
+		return
+	}
+	p.reloadTimer = time.AfterFunc(p.ReloadDebounce, func() {
+		p.Broadcast(Message{Type: "reload"})
+	})
+}
+
+// Close disconnects every connected browser and refuses new connections. Browsers keep trying to
+// reconnect and reload once they reach a new process, so when handing a listener over to a new
+// process, Close should only be called after that process is ready.
+func (p *PageReloader) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for c := range p.clients {
+		c.cancel()
+	}
+}
+
+// ReportProblem shows a problem in the overlay of every connected browser, including browsers that
+// connect later, until it is cleared with ClearProblem.
+func (p *PageReloader) ReportProblem(problem Problem) {
+	if problem.Level == "" {
+		problem.Level = "error"
+	}
+	p.retain("problem:"+problem.Key, Message{Type: "problem", Data: problem})
+}
+
+// ClearProblem removes the problem reported with key from the overlay.
+func (p *PageReloader) ClearProblem(key string) {
+	p.mu.Lock()
+	_, ok := p.retained["problem:"+key]
+	delete(p.retained, "problem:"+key)
+	p.mu.Unlock()
+	if ok {
+		p.Broadcast(Message{Type: "problem-clear", Data: key})
+	}
+}
+
+func (p *PageReloader) retain(key string, m Message) {
+	p.mu.Lock()
+	if p.retained == nil {
+		p.retained = map[string]Message{}
+	}
+	p.retained[key] = m
+	p.mu.Unlock()
+	p.Broadcast(m)
+}
+
+// ReloadCSS tells every connected browser to reload the stylesheets whose URL path ends with one of
+// paths, without reloading the page. All stylesheets are reloaded when no paths are given.
+func (p *PageReloader) ReloadCSS(paths ...string) {
+	if paths == nil {
+		paths = []string{}
+	}
+	p.Broadcast(Message{Type: "css", Data: paths})
+}
+
+func (p *PageReloader) register(cancel context.CancelFunc) (*Client, bool) {
+	c := &Client{messages: make(chan Message, 16), cancel: cancel}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, false
+	}
+	if p.clients == nil {
+		p.clients = map[*Client]struct{}{}
+	}
+	p.clients[c] = struct{}{}
+	for _, m := range p.retained {
+		c.Send(m)
+	}
+	return c, true
+}
+
+func (p *PageReloader) unregister(c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.clients, c)
+}
+
+// handle serves h at Path/name and below it. Mount the PageReloader at Path + "/" as well as Path
+// for its views to be reachable.
+func (p *PageReloader) handle(name string, h http.Handler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.checkName(name); err != nil {
+		return err
+	}
+	if p.routes == nil {
+		p.routes = map[string]http.Handler{}
+	}
+	p.routes[name] = h
+	return nil
+}
+
+// checkName returns an error when name is taken by a view or a plugin. p.mu must be held.
+func (p *PageReloader) checkName(name string) error {
+	_, used := p.plugins[name]
+	_, routed := p.routes[name]
+	if used || routed {
+		return fmt.Errorf("%w: %q is already in use", ErrInvalidParameters, name)
+	}
+	return nil
+}
+
+// ServeHTTP serves the socket at Path and the views of the attached features below it.
 func (p *PageReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	socket, err := websocket.Accept(w, r, nil)
+	if rest, ok := strings.CutPrefix(r.URL.Path, strings.TrimSuffix(p.Path, "/")+"/"); ok {
+		name, _, _ := strings.Cut(rest, "/")
+		p.mu.Lock()
+		h, ok := p.routes[name]
+		p.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+		return
+	}
+	socket, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: p.OriginPatterns})
 	if err != nil {
 		_, _ = w.Write([]byte("could not open websocket"))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -75,9 +565,40 @@ func (p *PageReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer socket.Close(websocket.StatusGoingAway, "server closing websocket")
 	ctx := r.Context()
-	socketCtx := socket.CloseRead(ctx)
+	socketCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c, ok := p.register(cancel)
+	if !ok {
+		return
+	}
+	defer p.unregister(c)
+	go func() {
+		defer cancel()
+		for {
+			var in struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := wsjson.Read(socketCtx, socket, &in); err != nil {
+				return
+			}
+			p.dispatch(socketCtx, c, Message{Type: in.Type, Data: in.Data})
+		}
+	}()
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
 	for {
-		_ = socket.Ping(socketCtx)
-		time.Sleep(time.Second * 2)
+		select {
+		case <-socketCtx.Done():
+			return
+		case m := <-c.messages:
+			if err := wsjson.Write(socketCtx, socket, m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := socket.Ping(socketCtx); err != nil {
+				return
+			}
+		}
 	}
 }
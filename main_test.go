@@ -23,7 +23,7 @@ func TestTemplate(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Could not render template. %v", err)
 	}
-	if !strings.Contains(b.String(), "new WebSocket(\"__test_path__\")") {
+	if !strings.Contains(b.String(), "new WebSocket(socketURL(\"__test_path__\"))") {
 		t.Fatalf("Did not insert path correctly for the websocket. Rendered %s", b.String())
 	}
 	if !regexp.MustCompile("setTimeout.*250").MatchString(b.String()) {
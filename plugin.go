@@ -0,0 +1,65 @@
+package autorefresh
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Plugin is a development tool built on the reload channel, such as a panel showing the state of the
+// application, registered on a PageReloader with Use. It contributes views served below the reloader
+// path, handlers of the messages browsers send over the socket, and a fragment of the client script.
+type Plugin interface {
+	// Name identifies the plugin, and is the path below the reloader path its Handler is served at.
+	Name() string
+	// Handler serves the requests to Path/Name and the paths below it, with that prefix stripped from
+	// the request path. It is nil for plugins without views.
+	Handler() http.Handler
+	// MessageHandlers returns the handlers of the messages browsers send, by message type. Prefix the
+	// types with the Name, so they do not collide with the messages of other plugins. Handlers of
+	// messages that change the state of the server should check the token the Script sends along
+	// from config.token with PageReloader.VerifyToken.
+	MessageHandlers() map[string]MessageHandler
+	// Script returns a fragment included in the client script of the pages, empty for none. It runs in
+	// the scope of the default script, after its core was set up and before the socket connects, and
+	// uses the autorefresh object to exchange messages: autorefresh.on registers a handler of the
+	// messages of a type, autorefresh.send sends a message to the server, and the "open" event is
+	// emitted each time the socket connected.
+	Script() template.JS
+}
+
+// Use registers plugin on the PageReloader. Its views are served by ServeHTTP, so the PageReloader
+// must be mounted below its path as well, e.g. with mux.Handle(p.Path+"/", p).
+func (p *PageReloader) Use(plugin Plugin) error {
+	name := plugin.Name()
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("%w: invalid plugin name %q", ErrInvalidParameters, name)
+	}
+	h := plugin.Handler()
+	// The name is checked and taken at once, so concurrent registrations cannot both get it.
+	p.mu.Lock()
+	if err := p.checkName(name); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	if p.plugins == nil {
+		p.plugins = map[string]Plugin{}
+	}
+	p.plugins[name] = plugin
+	if h != nil {
+		if p.routes == nil {
+			p.routes = map[string]http.Handler{}
+		}
+		p.routes[name] = http.StripPrefix(strings.TrimSuffix(p.Path, "/")+"/"+name, h)
+	}
+	p.mu.Unlock()
+
+	for typ, handler := range plugin.MessageHandlers() {
+		p.OnMessage(typ, handler)
+	}
+	if script := plugin.Script(); script != "" {
+		p.addScript(script)
+	}
+	return nil
+}
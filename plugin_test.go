@@ -0,0 +1,158 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+type flagsPlugin struct {
+	reloader *autorefresh.PageReloader
+	mu       sync.Mutex
+	flags    map[string]bool
+}
+
+func (f *flagsPlugin) Name() string { return "flags" }
+
+func (f *flagsPlugin) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "flags at "+r.URL.Path)
+	})
+}
+
+func (f *flagsPlugin) MessageHandlers() map[string]autorefresh.MessageHandler {
+	return map[string]autorefresh.MessageHandler{
+		"flags-get": func(_ context.Context, from *autorefresh.Client, _ autorefresh.Message) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			from.Send(autorefresh.Message{Type: "flags", Data: f.flags})
+		},
+		"flags-set": func(_ context.Context, _ *autorefresh.Client, m autorefresh.Message) {
+			var set struct {
+				Name  string `json:"name"`
+				Value bool   `json:"value"`
+				Token string `json:"token"`
+			}
+			if err := m.Decode(&set); err != nil || !f.reloader.VerifyToken(set.Token) {
+				return
+			}
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.flags[set.Name] = set.Value
+		},
+	}
+}
+
+func (f *flagsPlugin) Script() template.JS {
+	return `autorefresh.on("flags", function (flags) { console.log(flags); });`
+}
+
+func TestPlugin(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	if err := reloader.Use(&flagsPlugin{reloader: reloader, flags: map[string]bool{"beta": true}}); err != nil {
+		t.Fatalf("Could not use plugin. %v", err)
+	}
+	if err := reloader.Use(&flagsPlugin{}); !errors.Is(err, autorefresh.ErrInvalidParameters) {
+		t.Fatalf("Expected a duplicate plugin to be refused, got %v", err)
+	}
+	if _, err := autorefresh.NewRenderHistory(reloader); err != nil {
+		t.Fatalf("Could not create render history. %v", err)
+	}
+	if err := reloader.Use(&historyPlugin{}); !errors.Is(err, autorefresh.ErrInvalidParameters) {
+		t.Fatalf("Expected a plugin taking the path of a view to be refused, got %v", err)
+	}
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if !strings.Contains(script.String(), `autorefresh.on("flags"`) {
+		t.Fatalf("Plugin script was not included. Rendered %s", script.String())
+	}
+	token := regexp.MustCompile(`"token":"(\w+)"`).FindStringSubmatch(script.String())
+	if token == nil {
+		t.Fatalf("Script does not include the token. Rendered %s", script.String())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle(reloader.Path+"/", reloader)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	resp, err := http.Get(server.URL + reloader.Path + "/flags/beta")
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "flags at /beta" {
+		t.Fatalf("Unexpected plugin response %q", body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+reloader.Path, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	for _, set := range []map[string]any{
+		{"name": "beta", "value": false, "token": "forged"},
+		{"name": "dark", "value": true, "token": token[1]},
+	} {
+		if err := wsjson.Write(ctx, socket, autorefresh.Message{Type: "flags-set", Data: set}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wsjson.Write(ctx, socket, autorefresh.Message{Type: "flags-get"}); err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Type string          `json:"type"`
+		Data map[string]bool `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil || m.Type != "flags" || !m.Data["beta"] || !m.Data["dark"] {
+		t.Fatalf("Unexpected reply %+v %v", m, err)
+	}
+}
+
+func TestRenderHistoryRefusesPluginPath(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	if err := reloader.Use(&historyPlugin{}); err != nil {
+		t.Fatalf("Could not use plugin. %v", err)
+	}
+	if _, err := autorefresh.NewRenderHistory(reloader); !errors.Is(err, autorefresh.ErrInvalidParameters) {
+		t.Fatalf("Expected the render history to refuse the path of a plugin, got %v", err)
+	}
+}
+
+// historyPlugin takes the path of the render history view.
+type historyPlugin struct{}
+
+func (historyPlugin) Name() string { return "history" }
+
+func (historyPlugin) Handler() http.Handler { return http.NotFoundHandler() }
+
+func (historyPlugin) MessageHandlers() map[string]autorefresh.MessageHandler { return nil }
+
+func (historyPlugin) Script() template.JS { return "" }
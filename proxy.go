@@ -0,0 +1,201 @@
+package autorefresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proxy is a reverse proxy that sits in front of an application during development. It serves the
+// PageReloader socket itself so browsers stay connected while the application restarts, holds
+// incoming requests while the upstream is down and releases them once it is reachable again.
+//
+// Only the reloader path and the paths below it are reserved, everything else, including the
+// application's own websocket upgrades, is passed through. Redirects, cookies and links referring to
+// the upstream's address are rewritten to refer to the Proxy.
+//
+// Monitor must be running for the Proxy to notice the upstream going away and coming back.
+type Proxy struct {
+	Reloader *PageReloader
+	Upstream *url.URL
+	// Timeout is how long a request is held while the upstream is down before it is failed.
+	Timeout time.Duration
+	// HealthInterval is how often the upstream is probed while monitoring.
+	HealthInterval time.Duration
+	// Inject injects the reload script into the upstream's HTML documents, so the application does
+	// not need to render it itself.
+	Inject bool
+
+	proxy *httputil.ReverseProxy
+
+	mu      sync.Mutex
+	healthy bool
+	// ready is closed while the upstream is healthy and replaced when it goes down.
+	ready chan struct{}
+}
+
+var ErrUpstreamUnavailable = errors.New("Upstream unavailable")
+
+// inboundKey holds the request received by the Proxy, so it can be retried from scratch.
+type inboundKey struct{}
+
+type retryKey struct{}
+
+// NewProxy creates a Proxy forwarding to upstream and serving the reloader socket at reloader.Path.
+func NewProxy(upstream string, reloader *PageReloader) (*Proxy, error) {
+	if reloader == nil {
+		return nil, fmt.Errorf("%w: reloader is required", ErrInvalidParameters)
+	}
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid upstream %q", ErrInvalidParameters, upstream)
+	}
+	p := &Proxy{
+		Reloader:       reloader,
+		Upstream:       u,
+		Timeout:        time.Second * 30,
+		HealthInterval: time.Millisecond * 200,
+		ready:          make(chan struct{}),
+	}
+	p.proxy = httputil.NewSingleHostReverseProxy(u)
+	p.proxy.Director = p.director(p.proxy.Director)
+	p.proxy.ModifyResponse = p.modifyResponse
+	p.proxy.ErrorHandler = p.handleError
+	return p, nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == p.Reloader.Path || strings.HasPrefix(r.URL.Path, p.Reloader.Path+"/") {
+		p.Reloader.ServeHTTP(w, r)
+		return
+	}
+	if err := p.wait(r.Context()); err != nil {
+		p.unavailable(w, err)
+		return
+	}
+	r = withProxyOrigin(r)
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inboundKey{}, r)))
+}
+
+// Monitor probes the upstream until ctx is cancelled, releasing held requests when it comes up and
+// telling connected browsers to reload when it comes back after having been down.
+func (p *Proxy) Monitor(ctx context.Context) {
+	ticker := time.NewTicker(p.HealthInterval)
+	defer ticker.Stop()
+	wasDown := false
+	for {
+		up := p.probe(ctx)
+		p.setHealthy(up)
+		if !up {
+			wasDown = true
+		} else if wasDown {
+			wasDown = false
+			p.Reloader.Reload()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Healthy reports whether the upstream was reachable when it was last probed.
+func (p *Proxy) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.healthy
+}
+
+func (p *Proxy) probe(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.HealthInterval)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", upstreamAddr(p.Upstream))
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func (p *Proxy) setHealthy(healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if healthy == p.healthy {
+		return
+	}
+	p.healthy = healthy
+	if healthy {
+		close(p.ready)
+	} else {
+		p.ready = make(chan struct{})
+	}
+}
+
+// wait blocks until the upstream is healthy, the request is cancelled or the Timeout elapses.
+func (p *Proxy) wait(ctx context.Context) error {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("%w: not reachable after %s", ErrUpstreamUnavailable, p.Timeout)
+	}
+}
+
+// handleError marks the upstream as down when it cannot be reached. Requests without a body are
+// held and retried once, since the upstream most likely went away for a restart. The retry starts
+// from the inbound request, r being the one already rewritten for the upstream.
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		p.unavailable(w, err)
+		return
+	}
+	p.setHealthy(false)
+	inbound, ok := r.Context().Value(inboundKey{}).(*http.Request)
+	if !ok || r.Context().Value(retryKey{}) != nil || (inbound.Body != nil && inbound.Body != http.NoBody) {
+		p.unavailable(w, err)
+		return
+	}
+	if err := p.wait(r.Context()); err != nil {
+		p.unavailable(w, err)
+		return
+	}
+	p.proxy.ServeHTTP(w, inbound.WithContext(context.WithValue(inbound.Context(), retryKey{}, true)))
+}
+
+// unavailable writes an error page that includes the reload script, so the browser reloads itself
+// once the upstream is back.
+func (p *Proxy) unavailable(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	_, _ = fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>Upstream unavailable</title>")
+	_ = p.Reloader.Template.Execute(w, nil)
+	_, _ = fmt.Fprintf(w, "</head><body><h1>Upstream unavailable</h1><pre>%s</pre></body></html>\n", template.HTMLEscapeString(err.Error()))
+}
+
+func upstreamAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
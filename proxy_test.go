@@ -0,0 +1,325 @@
+package autorefresh_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestProxyHoldsRequestsUntilUpstreamIsUp(t *testing.T) {
+	t.Parallel()
+	// Reserve an address for the upstream, but only start serving on it after the request was sent.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen. %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	proxy, err := autorefresh.NewProxy("http://"+addr, reloader)
+	if err != nil {
+		t.Fatalf("Could not create proxy. %v", err)
+	}
+	proxy.HealthInterval = time.Millisecond * 20
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.Monitor(ctx)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/hello")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		results <- result{body: string(b), err: err}
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+	select {
+	case r := <-results:
+		t.Fatalf("Request was not held while the upstream was down. Got %q, %v", r.body, r.err)
+	default:
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Could not listen on upstream address. %v", err)
+	}
+	upstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from "+r.URL.Path)
+	})}
+	go func() { _ = upstream.Serve(l) }()
+	defer upstream.Close()
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatalf("Request failed. %v", r.err)
+		}
+		if r.body != "hello from /hello" {
+			t.Fatalf("Unexpected body %q", r.body)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Request was not released once the upstream came up")
+	}
+}
+
+func TestProxyRetriesInboundRequest(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen. %v", err)
+	}
+	addr := l.Addr().String()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path+" for "+r.Header.Get("X-Forwarded-For"))
+	})
+	upstream := &http.Server{Handler: handler}
+	go func() { _ = upstream.Serve(l) }()
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	proxy, err := autorefresh.NewProxy("http://"+addr+"/app", reloader)
+	if err != nil {
+		t.Fatalf("Could not create proxy. %v", err)
+	}
+	proxy.HealthInterval = time.Millisecond * 20
+	ctx, cancel := context.WithCancel(context.Background())
+	go proxy.Monitor(ctx)
+	for deadline := time.Now().Add(time.Second * 5); !proxy.Healthy(); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal("Upstream was not detected")
+		}
+	}
+	// Stop monitoring, so only the failing request notices the upstream going away.
+	cancel()
+	_ = upstream.Close()
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/hello")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		results <- result{body: string(b), err: err}
+	}()
+	for deadline := time.Now().Add(time.Second * 5); proxy.Healthy(); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal("Request did not fail to reach the upstream")
+		}
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Could not listen on upstream address. %v", err)
+	}
+	upstream = &http.Server{Handler: handler}
+	go func() { _ = upstream.Serve(l) }()
+	defer upstream.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.Monitor(ctx)
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatalf("Request failed. %v", r.err)
+		}
+		if r.body != "/app/hello for 127.0.0.1" {
+			t.Fatalf("Retried request was rewritten twice. Got %q", r.body)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Request was not retried once the upstream came up")
+	}
+}
+
+func TestProxyRewritesUpstreamResponses(t *testing.T) {
+	t.Parallel()
+	var upstreamURL string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1", Domain: "127.0.0.1"})
+			http.Redirect(w, r, upstreamURL+"/page", http.StatusFound)
+		case "/socket":
+			socket, err := websocket.Accept(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer socket.CloseNow()
+			var m string
+			if err := wsjson.Read(r.Context(), socket, &m); err == nil {
+				_ = wsjson.Write(r.Context(), socket, "echo "+m)
+			}
+		default:
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				t.Errorf("Upstream was asked for %q", r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = io.WriteString(zw, `<html><head></head><body><a href="`+upstreamURL+`/next">next</a></body></html>`)
+			_ = zw.Close()
+		}
+	}))
+	defer upstream.Close()
+	upstreamURL = upstream.URL
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	proxy, err := autorefresh.NewProxy(upstream.URL, reloader)
+	if err != nil {
+		t.Fatalf("Could not create proxy. %v", err)
+	}
+	proxy.Inject = true
+	proxy.HealthInterval = time.Millisecond * 20
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go proxy.Monitor(ctx)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(server.URL + "/redirect")
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != server.URL+"/page" {
+		t.Fatalf("Location was not rewritten. Got %s", location)
+	}
+	if cookie := resp.Header.Get("Set-Cookie"); !strings.Contains(cookie, "Domain=127.0.0.1") || strings.Contains(cookie, upstream.URL) {
+		t.Fatalf("Unexpected cookie %s", cookie)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	defer resp.Body.Close()
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Response was not recompressed. %v", err)
+	}
+	b, _ := io.ReadAll(zr)
+	if !strings.Contains(string(b), `<a href="`+server.URL+`/next">`) || !strings.Contains(string(b), "new WebSocket(") {
+		t.Fatalf("HTML was not rewritten. Got %s", b)
+	}
+
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/socket", nil)
+	if err != nil {
+		t.Fatalf("Application websocket was not passed through. %v", err)
+	}
+	defer socket.CloseNow()
+	var echo string
+	if err := wsjson.Write(ctx, socket, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wsjson.Read(ctx, socket, &echo); err != nil || echo != "echo hi" {
+		t.Fatalf("Unexpected websocket reply %q, %v", echo, err)
+	}
+}
+
+func TestProxyRewritesWithoutInjecting(t *testing.T) {
+	t.Parallel()
+	var upstreamURL string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/brotli" {
+			// An upstream ignoring Accept-Encoding.
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, "not really brotli")
+			return
+		}
+		_, _ = io.WriteString(w, `<html><body><a href="`+upstreamURL+`/next">next</a> <a href="`+upstreamURL+`1/other">other</a></body></html>`)
+	}))
+	defer upstream.Close()
+	upstreamURL = upstream.URL
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	proxy, err := autorefresh.NewProxy(upstream.URL, reloader)
+	if err != nil {
+		t.Fatalf("Could not create proxy. %v", err)
+	}
+	proxy.HealthInterval = time.Millisecond * 20
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go proxy.Monitor(ctx)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	for _, path := range []string{"/page", "/brotli"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Request failed. %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if path != "/page" {
+			continue
+		}
+		body := string(b)
+		if !strings.Contains(body, `<a href="`+server.URL+`/next">`) || !strings.Contains(body, `<a href="`+upstreamURL+`1/other">`) || strings.Contains(body, "new WebSocket(") {
+			t.Fatalf("Unexpected page %s", body)
+		}
+	}
+
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/__reload", nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	for {
+		var m struct {
+			Type string              `json:"type"`
+			Data autorefresh.Problem `json:"data"`
+		}
+		if err := wsjson.Read(ctx, socket, &m); err != nil {
+			t.Fatalf("Unsupported encoding was not reported. %v", err)
+		}
+		if m.Type == "problem" && strings.Contains(m.Data.Title, "/brotli was served with Content-Encoding br") {
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package autorefresh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"regexp"
+	texttemplate "text/template"
+	"time"
+)
+
+// TemplateExecutor is a set of templates, implemented by both html/template and text/template.
+type TemplateExecutor interface {
+	Name() string
+	ExecuteTemplate(w io.Writer, name string, data any) error
+}
+
+// renderFailure describes a failed template execution to the error page and the other browsers.
+type renderFailure struct {
+	Template string
+	Path     string
+	// Location is the file, line and column of the failing action, when known.
+	Location string
+	Action   string
+	DataType string
+	Message  string
+	Script   template.HTML
+}
+
+// execErrorPattern splits the message of a text/template ExecError into the location, the action and
+// the cause.
+var execErrorPattern = regexp.MustCompile(`(?s)^template: ([^\s]+?): executing "[^"]*" at <(.*?)>: (.*)$`)
+
+// Render executes the template name of t, or t itself when name is empty, with data into a buffer,
+// and writes the result to w once it succeeded, so a failure does not leave a truncated page behind.
+// When execution fails, w gets a development error page naming the template, the failing action and
+// the type of data instead, which reloads once the template is fixed, and the error is shown in the
+// overlay of the other connected browsers until the template renders again. The error is returned,
+// the response is written either way.
+//
+// Executions are recorded in the toolbar of the request, see RecordTemplateData.
+func (p *PageReloader) Render(w http.ResponseWriter, r *http.Request, t TemplateExecutor, name string, data any) error {
+	if name == "" {
+		name = t.Name()
+	}
+	var buf bytes.Buffer
+	start := time.Now()
+	err := t.ExecuteTemplate(&buf, name, data)
+	RecordTemplateData(r.Context(), name, time.Since(start), data)
+	if err == nil {
+		p.ClearProblem("render:" + name)
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		_, _ = buf.WriteTo(w)
+		return nil
+	}
+
+	failure := renderFailure{
+		Template: name,
+		Path:     r.URL.Path,
+		DataType: fmt.Sprintf("%T", data),
+		Message:  err.Error(),
+		Script:   template.HTML(p.renderScript()),
+	}
+	var execErr texttemplate.ExecError
+	if errors.As(err, &execErr) {
+		if m := execErrorPattern.FindStringSubmatch(execErr.Err.Error()); m != nil {
+			failure.Location, failure.Action, failure.Message = m[1], m[2], m[3]
+		}
+	}
+	detail := failure.Message
+	if failure.Action != "" {
+		detail = fmt.Sprintf("%s: {{ %s }}: %s", failure.Location, failure.Action, failure.Message)
+	}
+	p.ReportProblem(Problem{
+		Key:    "render:" + name,
+		Title:  fmt.Sprintf("Template %s failed on %s", name, r.URL.Path),
+		Detail: fmt.Sprintf("%s\ndata: %s", detail, failure.DataType),
+	})
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = renderErrorTemplate.Execute(w, failure)
+	return err
+}
+
+var renderErrorTemplate = template.Must(template.New("render-error").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Template {{ .Template }} failed</title>
+		{{ .Script }}
+		<style>
+			body { margin: 2rem; font: 14px/1.5 system-ui, sans-serif; }
+			h1 { color: #cf222e; font-size: 1.4rem; }
+			th { text-align: left; padding-right: 1rem; vertical-align: top; }
+			td, pre { font-family: monospace; }
+			pre { white-space: pre-wrap; background: #f6f8fa; padding: 1rem; }
+		</style>
+	</head>
+	<body>
+		<h1>Template {{ .Template }} failed on {{ .Path }}</h1>
+		<table>
+			{{ if .Location }}<tr><th>Location</th><td>{{ .Location }}</td></tr>{{ end }}
+			{{ if .Action }}<tr><th>Action</th><td>{{ "{{" }} {{ .Action }} {{ "}}" }}</td></tr>{{ end }}
+			<tr><th>Data</th><td>{{ .DataType }}</td></tr>
+		</table>
+		<pre>{{ .Message }}</pre>
+		<p>The page reloads along with the other pages once the template is fixed.</p>
+	</body>
+</html>
+`))
@@ -0,0 +1,68 @@
+package autorefresh_test
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	tmpl := template.Must(template.New("users.html").Parse("<html><body><p>start</p>{{ .Missing.Field }}</body></html>"))
+	template.Must(tmpl.New("ok.html").Parse("<p>{{ . }}</p>"))
+
+	rec := httptest.NewRecorder()
+	err = reloader.Render(rec, httptest.NewRequest(http.MethodGet, "/users", nil), tmpl, "", map[string]any{"Missing": 1})
+	if err == nil {
+		t.Fatal("Expected the execution error to be returned")
+	}
+	body := rec.Body.String()
+	if rec.Code != http.StatusInternalServerError || strings.Contains(body, "<p>start</p>") {
+		t.Fatalf("Expected only the error page, got %d %s", rec.Code, body)
+	}
+	for _, expected := range []string{"users.html:1:", "{{ .Missing.Field }}", "map[string]interface {}", "<script"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Error page is missing %q. Got %s", expected, body)
+		}
+	}
+
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	var m struct {
+		Type string `json:"type"`
+		Data any    `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil || m.Type != "problem" {
+		t.Fatalf("The error was not pushed to the browsers. Got %+v %v", m, err)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := reloader.Render(rec, httptest.NewRequest(http.MethodGet, "/users", nil), tmpl, "users.html", map[string]any{"Missing": struct{ Field int }{}}); err != nil {
+		t.Fatalf("Render failed. %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html><body><p>start</p>0</body></html>" {
+		t.Fatalf("Unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil || m.Type != "problem-clear" || m.Data != "render:users.html" {
+		t.Fatalf("The error was not cleared. Got %+v %v", m, err)
+	}
+}
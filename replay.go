@@ -0,0 +1,149 @@
+package autorefresh
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// replayCookie tells the client script that the submission it just made went to a route that is
+// safe to replay.
+const replayCookie = "autorefresh-replay"
+
+// ReplaySafe is a middleware marking the routes of next as safe to replay with
+// PageReloader.ReplayForms, such as POST handlers that can run repeatedly with the same form during
+// development. Submissions to other routes are not offered for replay.
+func ReplaySafe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.SetCookie(w, &http.Cookie{
+				Name:     replayCookie,
+				Value:    url.QueryEscape(r.Method + " " + r.URL.Path),
+				Path:     "/",
+				MaxAge:   60,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// replayScript records the last form submission in sessionStorage. Submissions with files are not
+// recorded, since files cannot be stored. The page the submission led to, after redirects, is
+// remembered, and a reload of that page replays the submission once ReplaySafe marked its route,
+// or offers to when config.autoReplay is not set.
+const replayScript template.JS = `
+		(function () {
+			const key = "autorefresh:replay";
+			let record = JSON.parse(sessionStorage.getItem(key));
+			function save() {
+				sessionStorage.setItem(key, JSON.stringify(record));
+			}
+			function path(href) {
+				return new URL(href, window.location.href).pathname;
+			}
+
+			document.addEventListener("submit", function (event) {
+				const form = event.target;
+				if (!event.isTrusted || !(form instanceof HTMLFormElement)) {
+					return;
+				}
+				const method = (event.submitter && event.submitter.getAttribute("formmethod") || form.method).toUpperCase();
+				if (method === "GET" || method === "DIALOG") {
+					return;
+				}
+				let data;
+				try {
+					data = new FormData(form, event.submitter);
+				} catch (e) {
+					data = new FormData(form);
+				}
+				const entries = [];
+				for (const entry of data.entries()) {
+					if (typeof entry[1] !== "string") {
+						return;
+					}
+					entries.push(entry);
+				}
+				record = {
+					method: method,
+					action: event.submitter && event.submitter.formAction || form.action,
+					enctype: event.submitter && event.submitter.getAttribute("formenctype") || form.enctype,
+					entries: entries,
+					safe: false,
+					result: null,
+				};
+				save();
+			}, true);
+
+			if (record !== null) {
+				if (record.result === null) {
+					record.result = window.location.href;
+				}
+				const cookie = document.cookie.split("; ").find(function (c) {
+					return c.indexOf("autorefresh-replay=") === 0;
+				});
+				if (cookie) {
+					const marked = decodeURIComponent(cookie.slice("autorefresh-replay=".length).replace(/\+/g, " "));
+					record.safe = record.safe || marked === record.method + " " + path(record.action);
+					document.cookie = "autorefresh-replay=; Max-Age=0; Path=/";
+				}
+				save();
+			}
+
+			function replay() {
+				const form = document.createElement("form");
+				form.method = "POST";
+				form.action = record.action;
+				form.enctype = record.enctype;
+				form.style.display = "none";
+				record.entries.forEach(function (entry) {
+					const input = document.createElement("input");
+					input.type = "hidden";
+					input.name = entry[0];
+					input.value = entry[1];
+					form.appendChild(input);
+				});
+				record.result = null;
+				save();
+				document.body.appendChild(form);
+				HTMLFormElement.prototype.submit.call(form);
+			}
+			function offer(reload) {
+				let banner = document.getElementById("autorefresh-replay");
+				if (banner !== null) {
+					banner.remove();
+				}
+				banner = document.createElement("div");
+				banner.id = "autorefresh-replay";
+				banner.style.cssText = "position:fixed;left:50%;top:8px;transform:translateX(-50%);z-index:2147483647;padding:6px 10px;background:#222;color:#eee;font:12px monospace;border-radius:4px;";
+				banner.textContent = "The application restarted. ";
+				const again = document.createElement("button");
+				again.textContent = "replay " + record.method + " " + path(record.action);
+				again.style.cssText = "font:inherit;margin-right:4px;";
+				again.onclick = function () {
+					autorefresh.emit("beforereload");
+					replay();
+				};
+				const plain = document.createElement("button");
+				plain.textContent = "reload";
+				plain.style.cssText = "font:inherit;";
+				plain.onclick = reload;
+				banner.appendChild(again);
+				banner.appendChild(plain);
+				document.body.appendChild(banner);
+			}
+
+			const reload = autorefresh.reload;
+			autorefresh.reload = function () {
+				if (record === null || !record.safe || record.result !== window.location.href || !document.body) {
+					reload();
+				} else if (config.autoReplay) {
+					autorefresh.emit("beforereload");
+					replay();
+				} else {
+					offer(reload);
+				}
+			};
+		})();
+`
@@ -0,0 +1,50 @@
+package autorefresh_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestReplaySafe(t *testing.T) {
+	t.Parallel()
+	handler := autorefresh.ReplaySafe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/new?draft=1", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusSeeOther || len(cookies) != 1 {
+		t.Fatalf("Expected the response of the route with a cookie, got %d %v", rec.Code, cookies)
+	}
+	route, err := url.QueryUnescape(cookies[0].Value)
+	if cookies[0].Name != "autorefresh-replay" || err != nil || route != "POST /users/new" || cookies[0].Path != "/" || cookies[0].MaxAge <= 0 {
+		t.Fatalf("Route was not marked as safe to replay. Got %+v", cookies[0])
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/users/new", nil))
+		if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+			t.Fatalf("%s request was marked. Got %v", method, cookies)
+		}
+	}
+
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	without, _ := renderScript(t, reloader)
+	reloader.ReplayForms = true
+	reloader.AutoReplay = true
+	with, config := renderScript(t, reloader)
+	if without.ReplayForms || !with.ReplayForms || len(with.Fragments) != len(without.Fragments)+1 {
+		t.Fatalf("Expected ReplayForms to add its script, got %d fragments without and %d with", len(without.Fragments), len(with.Fragments))
+	}
+	if config["autoReplay"] != true {
+		t.Fatalf("Expected autoReplay in config %v", config)
+	}
+}
@@ -0,0 +1,231 @@
+package autorefresh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// proxyOriginKey holds the scheme and host the browser used to reach the Proxy.
+type proxyOriginKey struct{}
+
+type proxyOrigin struct {
+	scheme string
+	host   string
+}
+
+func withProxyOrigin(r *http.Request) *http.Request {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return r.WithContext(context.WithValue(r.Context(), proxyOriginKey{}, proxyOrigin{scheme: scheme, host: r.Host}))
+}
+
+// director extends the default director of the reverse proxy. The upstream is asked for gzip or
+// uncompressed responses only, since those are the encodings the Proxy can rewrite and inject the
+// script into.
+func (p *Proxy) director(base func(*http.Request)) func(*http.Request) {
+	return func(r *http.Request) {
+		base(r)
+		if origin, ok := r.Context().Value(proxyOriginKey{}).(proxyOrigin); ok {
+			r.Header.Set("X-Forwarded-Proto", origin.scheme)
+			r.Header.Set("X-Forwarded-Host", origin.host)
+		}
+		if r.Header.Get("Accept-Encoding") != "" {
+			if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				r.Header.Set("Accept-Encoding", "gzip")
+			} else {
+				r.Header.Del("Accept-Encoding")
+			}
+		}
+	}
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// modifyResponse rewrites references to the upstream in redirects, cookies and HTML documents so
+// they point at the Proxy instead, and injects the reload script into HTML documents when Inject
+// is set. Upgraded connections, such as the application's own websockets, are passed through.
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return nil
+	}
+	origin, ok := resp.Request.Context().Value(proxyOriginKey{}).(proxyOrigin)
+	if !ok {
+		return nil
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", p.rewriteURLs(location, origin))
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			resp.Header.Add("Set-Cookie", p.rewriteCookieDomain(cookie, origin))
+		}
+	}
+	if resp.Request.Method == http.MethodHead || !isHTMLResponse(resp.Header) {
+		return nil
+	}
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "gzip" && encoding != "identity" {
+		// Other encodings, such as br, cannot be decoded with the standard library. The upstream was
+		// asked not to use them, so show that it ignored that instead of passing the page on silently.
+		p.Reloader.ReportProblem(Problem{
+			Key:    "proxy:encoding",
+			Level:  "warning",
+			Title:  fmt.Sprintf("%s was served with Content-Encoding %s", resp.Request.URL.Path, encoding),
+			Detail: "The proxy can only rewrite and inject the reload script into gzip or uncompressed HTML documents. Disable " + encoding + " compression in the application while developing.",
+		})
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+	}
+	body = []byte(p.rewriteURLs(string(body), origin))
+	if p.Inject {
+		var script bytes.Buffer
+		if err := p.Reloader.Template.Execute(&script, nil); err != nil {
+			return err
+		}
+		body = InjectScript(body, script.Bytes())
+	}
+	if encoding == "gzip" {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		_, _ = zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = b.Bytes()
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	// The body no longer matches what the upstream validated.
+	resp.Header.Del("Etag")
+	return nil
+}
+
+func isHTMLResponse(h http.Header) bool {
+	mediaType := strings.TrimSpace(strings.Split(h.Get("Content-Type"), ";")[0])
+	return strings.EqualFold(mediaType, "text/html")
+}
+
+// upstreamHosts returns the hosts the upstream can be referred to by. Loopback hosts are
+// interchangeable, so links to 127.0.0.1 are rewritten as well when the upstream is localhost.
+func (p *Proxy) upstreamHosts() []string {
+	port := p.Upstream.Port()
+	hostname := p.Upstream.Hostname()
+	hosts := []string{p.Upstream.Host}
+	if ip := net.ParseIP(hostname); hostname == "localhost" || (ip != nil && ip.IsLoopback()) {
+		for _, alias := range []string{"localhost", "127.0.0.1", "[::1]"} {
+			h := alias
+			if port != "" {
+				h += ":" + port
+			}
+			if !contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
+// rewriteURLs replaces absolute and protocol-relative URLs pointing at the upstream in s with URLs
+// pointing at the Proxy. A host only matches when it is not followed by more of a host name or port,
+// so localhost:30001 is left alone for an upstream at localhost:3000.
+func (p *Proxy) rewriteURLs(s string, origin proxyOrigin) string {
+	var hosts []string
+	for _, host := range p.upstreamHosts() {
+		if host != origin.host {
+			hosts = append(hosts, regexp.QuoteMeta(host))
+		}
+	}
+	if len(hosts) == 0 {
+		return s
+	}
+	// Longer hosts first, since the first alternative that matches is used.
+	sort.Slice(hosts, func(i, j int) bool { return len(hosts[i]) > len(hosts[j]) })
+	pattern := regexp.MustCompile(`(?i)(https?:|wss?:)?//(?:` + strings.Join(hosts, "|") + `)`)
+	socketScheme := "ws"
+	if origin.scheme == "https" {
+		socketScheme = "wss"
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range pattern.FindAllStringSubmatchIndex(s, -1) {
+		if m[1] < len(s) && isHostByte(s[m[1]]) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		if m[2] >= 0 {
+			if scheme := strings.ToLower(s[m[2]:m[3]]); strings.HasPrefix(scheme, "ws") {
+				b.WriteString(socketScheme + ":")
+			} else {
+				b.WriteString(origin.scheme + ":")
+			}
+		}
+		b.WriteString("//" + origin.host)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isHostByte reports whether c can continue a host name or port.
+func isHostByte(c byte) bool {
+	return c == '.' || c == '-' || c == ':' || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rewriteCookieDomain replaces a Domain attribute naming the upstream host with the Proxy's host.
+func (p *Proxy) rewriteCookieDomain(cookie string, origin proxyOrigin) string {
+	parts := strings.Split(cookie, ";")
+	proxyHost := origin.host
+	if h, _, err := net.SplitHostPort(proxyHost); err == nil {
+		proxyHost = h
+	}
+	for i, part := range parts {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(name, "domain") {
+			continue
+		}
+		domain := strings.TrimPrefix(strings.ToLower(value), ".")
+		for _, host := range p.upstreamHosts() {
+			if u, err := url.Parse("//" + host); err == nil && u.Hostname() == domain {
+				parts[i] = " Domain=" + proxyHost
+				break
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,70 @@
+package autorefresh
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// ScriptData holds the settings of a PageReloader for client script templates. The default Script
+// passes it to the "autorefresh-head" and "autorefresh-init" templates it calls, which are empty and
+// can be defined after New to extend it: the first is rendered before the script element, and the
+// second inside it, after the features were set up and before the socket connects.
+//
+// Templates set with SetScript get it from the autorefreshData function. They can also call path and
+// refreshRate, autorefreshConfig, returning the config object of the default script, and
+// autorefreshScripts, returning the client script fragments of the enabled features, which expect
+// the variables of the default script.
+type ScriptData struct {
+	// Path is the path of the socket, relative to the page.
+	Path        string
+	RefreshRate uint
+	// Token authenticates the messages of the page that make the server act, such as invoking an
+	// action.
+	Token                  string
+	PreserveScroll         bool
+	Sync                   bool
+	ReplayForms            bool
+	AutoReplay             bool
+	HTMX                   bool
+	PreserveState          bool
+	PreserveLocalStorage   []string
+	PreserveSessionStorage []string
+	CoordinateFrames       bool
+	// OriginPatterns are the host patterns of other origins allowed to connect to the socket.
+	OriginPatterns []string
+	// ReloadDebounce is the duration within which Reload calls are coalesced into a single reload.
+	ReloadDebounce time.Duration
+	// Fragments are the client script fragments of the enabled features, as autorefreshScripts
+	// returns them.
+	Fragments []template.JS
+}
+
+func (p *PageReloader) scriptData() ScriptData {
+	return ScriptData{
+		Path:                   p.Path,
+		RefreshRate:            p.RefreshRate,
+		Token:                  p.token,
+		PreserveScroll:         p.PreserveScroll,
+		Sync:                   p.Sync,
+		ReplayForms:            p.ReplayForms,
+		AutoReplay:             p.AutoReplay,
+		HTMX:                   p.HTMX,
+		PreserveState:          p.PreserveState,
+		PreserveLocalStorage:   p.PreserveLocalStorage,
+		PreserveSessionStorage: p.PreserveSessionStorage,
+		CoordinateFrames:       p.CoordinateFrames,
+		OriginPatterns:         p.OriginPatterns,
+		ReloadDebounce:         p.ReloadDebounce,
+		Fragments:              p.scripts(),
+	}
+}
+
+// SetScript replaces the client script rendered by Template with the html/template src, see
+// ScriptData for what it has access to. It must be called before Template is executed.
+func (p *PageReloader) SetScript(src string) error {
+	if _, err := p.Template.Parse(src); err != nil {
+		return fmt.Errorf("%w: %w", ErrTemplateParsing, err)
+	}
+	return nil
+}
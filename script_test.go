@@ -0,0 +1,103 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestScriptHooks(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	template.Must(reloader.Template.New("autorefresh-head").Parse(`<link rel="preconnect" href="{{ .Path }}">`))
+	template.Must(reloader.Template.New("autorefresh-init").Parse(`autorefresh.on("open", function () { console.log({{ .Path }}); });`))
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	rendered := script.String()
+	head := strings.Index(rendered, `<link rel="preconnect" href="/__reload">`)
+	init := strings.Index(rendered, `console.log("/__reload")`)
+	if head < 0 || head > strings.Index(rendered, "<script>") || init < 0 || init > strings.Index(rendered, "autorefresh.connect();") {
+		t.Fatalf("Hooks were not rendered in place. Rendered %s", rendered)
+	}
+}
+
+func TestSetScript(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	reloader.Sync = true
+	reloader.OriginPatterns = []string{"example.com"}
+	reloader.ReloadDebounce = time.Millisecond * 300
+	err = reloader.SetScript(`{{ with autorefreshData }}<script>start({{ .Path }}, {{ .RefreshRate }}, {{ .Sync }}, {{ .OriginPatterns }}, {{ .ReloadDebounce.Milliseconds }});</script>{{ end }}`)
+	if err != nil {
+		t.Fatalf("Could not set script. %v", err)
+	}
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if expected := `<script>start("/__reload",  100 ,  true , ["example.com"],  300 );</script>`; script.String() != expected {
+		t.Fatalf("Expected %s, got %s", expected, script.String())
+	}
+	if err := reloader.SetScript("{{ .Missing"); err == nil {
+		t.Fatal("Expected an invalid template to be refused")
+	}
+}
+
+func TestScriptFuncs(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	if _, err := template.New("layout").Parse(autorefresh.Script); err == nil {
+		t.Fatal("Expected Script to need the functions of a reloader")
+	}
+	layout, err := template.New("layout").Funcs(reloader.Funcs()).Parse(autorefresh.Script)
+	if err != nil {
+		t.Fatalf("Could not parse Script. %v", err)
+	}
+	var own, script bytes.Buffer
+	if err := layout.Execute(&own, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if own.String() != script.String() {
+		t.Fatalf("Expected the script of the reloader, got %s", own.String())
+	}
+}
+
+// renderScript returns the ScriptData and the config the client script of reloader is rendered with,
+// as the page receives them.
+func renderScript(t *testing.T, reloader *autorefresh.PageReloader) (autorefresh.ScriptData, map[string]any) {
+	t.Helper()
+	tmpl := template.Must(template.New("data").Funcs(reloader.Funcs()).Parse(`<script>{{ autorefreshData }}</script><script>{{ autorefreshConfig }}</script>`))
+	var script bytes.Buffer
+	if err := tmpl.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	rendered := strings.Split(strings.TrimSuffix(strings.TrimPrefix(script.String(), "<script>"), "</script>"), "</script><script>")
+	var data autorefresh.ScriptData
+	var config map[string]any
+	if err := json.Unmarshal([]byte(rendered[0]), &data); err != nil {
+		t.Fatalf("Could not decode script data. %v", err)
+	}
+	if err := json.Unmarshal([]byte(rendered[1]), &config); err != nil {
+		t.Fatalf("Could not decode config. %v", err)
+	}
+	return data, config
+}
@@ -0,0 +1,81 @@
+package autorefresh
+
+import "html/template"
+
+// stateScript snapshots the state of client-side routed applications into sessionStorage before a
+// reload, and restores it when the page loads again, before the application's own scripts run when
+// the script is included at the top of the head. The snapshot holds the URL including its hash,
+// history.state, the configured storage keys and the state of the hooks registered by the
+// application with autorefresh.preserve. It is only restored on the page it was taken on.
+const stateScript template.JS = `
+		(function () {
+			const key = "autorefresh:state";
+			const hooks = {};
+			const snapshot = JSON.parse(sessionStorage.getItem(key));
+			sessionStorage.removeItem(key);
+			const restored = snapshot !== null && snapshot.path === window.location.pathname ? snapshot : null;
+
+			function copy(storage, keys) {
+				const values = {};
+				keys.forEach(function (k) {
+					const value = storage.getItem(k);
+					if (value !== null) {
+						values[k] = value;
+					}
+				});
+				return values;
+			}
+			function paste(storage, values) {
+				Object.keys(values).forEach(function (k) {
+					storage.setItem(k, values[k]);
+				});
+			}
+
+			if (restored !== null) {
+				try {
+					history.replaceState(restored.state, "", restored.href);
+				} catch (e) {
+					console.warn("autorefresh: could not restore history.state", e);
+				}
+				paste(localStorage, restored.local);
+				paste(sessionStorage, restored.session);
+			}
+
+			// preserve registers a hook saving state that a reload loses, such as an in-memory store.
+			// save returns a JSON serializable value, passed to restore after the reload. restore is
+			// called right away when a snapshot holds a value for name.
+			autorefresh.preserve = function (name, hook) {
+				hooks[name] = hook;
+				if (restored !== null && Object.prototype.hasOwnProperty.call(restored.custom, name)) {
+					const value = restored.custom[name];
+					delete restored.custom[name];
+					hook.restore(value);
+				}
+			};
+
+			autorefresh.on("beforereload", function () {
+				const custom = {};
+				Object.keys(hooks).forEach(function (name) {
+					try {
+						custom[name] = hooks[name].save();
+					} catch (e) {
+						console.warn("autorefresh: could not save the state of " + name, e);
+					}
+				});
+				let state = null;
+				try {
+					state = JSON.parse(JSON.stringify(history.state));
+				} catch (e) {
+					console.warn("autorefresh: history.state cannot be preserved", e);
+				}
+				sessionStorage.setItem(key, JSON.stringify({
+					path: window.location.pathname,
+					href: window.location.href,
+					state: state,
+					local: copy(localStorage, config.localStorageKeys || []),
+					session: copy(sessionStorage, config.sessionStorageKeys || []),
+					custom: custom,
+				}));
+			});
+		})();
+`
@@ -0,0 +1,27 @@
+package autorefresh_test
+
+import (
+	"reflect"
+	"testing"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestPreserveState(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	without, _ := renderScript(t, reloader)
+	reloader.PreserveState = true
+	reloader.PreserveLocalStorage = []string{"cart"}
+	reloader.PreserveSessionStorage = []string{"draft", "step"}
+	with, config := renderScript(t, reloader)
+	if without.PreserveState || !with.PreserveState || len(with.Fragments) != len(without.Fragments)+1 {
+		t.Fatalf("Expected PreserveState to add its script, got %d fragments without and %d with", len(without.Fragments), len(with.Fragments))
+	}
+	if local, session := config["localStorageKeys"], config["sessionStorageKeys"]; !reflect.DeepEqual(local, []any{"cart"}) || !reflect.DeepEqual(session, []any{"draft", "step"}) {
+		t.Fatalf("Unexpected storage keys in config %v", config)
+	}
+}
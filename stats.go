@@ -0,0 +1,298 @@
+package autorefresh
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RuntimeStats streams samples of the Go runtime to a panel of sparklines in the pages connected to a
+// PageReloader, to spot leaking goroutines or memory while iterating on handlers. It is also a handler
+// serving pprof profiles for download from the panel, below the path of the PageReloader.
+type RuntimeStats struct {
+	Reloader *PageReloader
+	// Interval is the time between samples.
+	Interval time.Duration
+	// History is the number of samples kept for pages connecting later, and shown in the panel.
+	History int
+
+	mu      sync.Mutex
+	samples []RuntimeSample
+}
+
+// RuntimeSample is a sample of the Go runtime.
+type RuntimeSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines uint64    `json:"goroutines"`
+	// HeapBytes is the memory occupied by live and not yet swept heap objects.
+	HeapBytes uint64 `json:"heapBytes"`
+	GCCycles  uint64 `json:"gcCycles"`
+	// GCPauses is the number of stop-the-world pauses for garbage collection since the previous
+	// sample, and GCPauseMax an upper bound of the longest of them in seconds.
+	GCPauses   uint64  `json:"gcPauses"`
+	GCPauseMax float64 `json:"gcPauseMax"`
+}
+
+// runtimeState is sent to a browser when it connects, so its panel starts with the recent samples.
+type runtimeState struct {
+	Path    string          `json:"path"`
+	History int             `json:"history"`
+	Samples []RuntimeSample `json:"samples"`
+}
+
+const (
+	goroutinesMetric = "/sched/goroutines:goroutines"
+	heapMetric       = "/memory/classes/heap/objects:bytes"
+	gcCyclesMetric   = "/gc/cycles/total:gc-cycles"
+	gcPausesMetric   = "/gc/pauses:seconds"
+	// maxCPUProfile bounds how long a CPU profile requested from the panel runs.
+	maxCPUProfile = time.Minute
+)
+
+// NewRuntimeStats creates RuntimeStats showing in the pages connected to reloader, with its
+// handler served at "runtime" below the reloader path.
+func NewRuntimeStats(reloader *PageReloader) (*RuntimeStats, error) {
+	s := &RuntimeStats{Reloader: reloader, Interval: time.Second, History: 60}
+	if err := reloader.handle("runtime", s); err != nil {
+		return nil, err
+	}
+	reloader.addScript(runtimeStatsScript)
+	reloader.OnMessage("runtime", func(_ context.Context, from *Client, _ Message) {
+		s.mu.Lock()
+		state := runtimeState{
+			Path:    strings.TrimSuffix(s.Reloader.Path, "/") + "/runtime",
+			History: s.History,
+			Samples: append([]RuntimeSample{}, s.samples...),
+		}
+		s.mu.Unlock()
+		from.Send(Message{Type: "runtime-state", Data: state})
+	})
+	return s, nil
+}
+
+// Run samples the runtime every Interval and streams the samples to the browsers, until ctx is
+// cancelled.
+func (s *RuntimeStats) Run(ctx context.Context) {
+	descriptions := map[string]bool{}
+	for _, d := range metrics.All() {
+		descriptions[d.Name] = true
+	}
+	var read []metrics.Sample
+	for _, name := range []string{goroutinesMetric, heapMetric, gcCyclesMetric, gcPausesMetric} {
+		if descriptions[name] {
+			read = append(read, metrics.Sample{Name: name})
+		}
+	}
+	var previousPauses []uint64
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		metrics.Read(read)
+		sample := RuntimeSample{Time: time.Now()}
+		for _, m := range read {
+			switch m.Name {
+			case goroutinesMetric:
+				sample.Goroutines = m.Value.Uint64()
+			case heapMetric:
+				sample.HeapBytes = m.Value.Uint64()
+			case gcCyclesMetric:
+				sample.GCCycles = m.Value.Uint64()
+			case gcPausesMetric:
+				h := m.Value.Float64Histogram()
+				sample.GCPauses, sample.GCPauseMax = pausesSince(previousPauses, h)
+				previousPauses = append(previousPauses[:0], h.Counts...)
+			}
+		}
+		s.mu.Lock()
+		s.samples = appendLimited(s.samples, sample, s.History)
+		s.mu.Unlock()
+		s.Reloader.Broadcast(Message{Type: "runtime", Data: sample})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pausesSince returns the number of pauses recorded in h since the counts in previous, and the
+// upper bound of the bucket of the longest of them. Nothing is reported for the first sample.
+func pausesSince(previous []uint64, h *metrics.Float64Histogram) (uint64, float64) {
+	if previous == nil {
+		return 0, 0
+	}
+	var count uint64
+	longest := 0.0
+	for i, c := range h.Counts {
+		if i < len(previous) {
+			c -= previous[i]
+		}
+		if c == 0 {
+			continue
+		}
+		count += c
+		upper := h.Buckets[i+1]
+		if math.IsInf(upper, 1) {
+			upper = h.Buckets[i]
+		}
+		longest = upper
+	}
+	return count, longest
+}
+
+// ServeHTTP writes the profile named by the profile query parameter as an attachment. Besides the
+// profiles of runtime/pprof, such as heap and goroutine, "cpu" profiles the CPU for the number of
+// seconds given by the seconds parameter, 10 by default.
+func (s *RuntimeStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("profile")
+	filename := fmt.Sprintf("%s-%s.pb.gz", name, time.Now().Format("20060102-150405"))
+	if name == "cpu" {
+		duration := time.Second * 10
+		if seconds, err := strconv.Atoi(r.URL.Query().Get("seconds")); err == nil && seconds > 0 {
+			duration = time.Second * time.Duration(seconds)
+		}
+		if duration > maxCPUProfile {
+			duration = maxCPUProfile
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if err := pprof.StartCPUProfile(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		select {
+		case <-time.After(duration):
+		case <-r.Context().Done():
+		}
+		pprof.StopCPUProfile()
+		return
+	}
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		http.Error(w, fmt.Sprintf("unknown profile %q", name), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	_ = profile.WriteTo(w, 0)
+}
+
+// runtimeStatsScript renders the panel in the top right corner, collapsed to a toggle until opened.
+// Whether it is open is remembered per device.
+const runtimeStatsScript template.JS = `
+		(function () {
+			let samples = [];
+			let history = 0;
+			let profilePath = "";
+			let open = localStorage.getItem("autorefresh:runtime-open") === "1";
+			let root = null;
+			const series = [
+				{ label: "goroutines", value: function (s) { return s.goroutines; }, format: String },
+				{ label: "heap", value: function (s) { return s.heapBytes; }, format: function (v) { return (v / 1048576).toFixed(1) + "MiB"; } },
+				{ label: "gc pause", value: function (s) { return s.gcPauseMax; }, format: function (v) { return (v * 1000).toFixed(2) + "ms"; } },
+			];
+
+			// limited keeps the last History samples, all of them when it is 0.
+			function limited(values) {
+				return history > 0 ? values.slice(-history) : values;
+			}
+			function sparkline(values) {
+				const canvas = document.createElement("canvas");
+				canvas.width = 160;
+				canvas.height = 28;
+				const context = canvas.getContext("2d");
+				const max = Math.max.apply(null, values.concat([1e-9]));
+				context.strokeStyle = "#7fd4ff";
+				context.beginPath();
+				values.forEach(function (v, i) {
+					const x = values.length === 1 ? canvas.width : i * canvas.width / (values.length - 1);
+					const y = canvas.height - 2 - v / max * (canvas.height - 4);
+					if (i === 0) {
+						context.moveTo(x, y);
+					} else {
+						context.lineTo(x, y);
+					}
+				});
+				context.stroke();
+				return canvas;
+			}
+			function render() {
+				if (!document.body) {
+					return;
+				}
+				if (root === null) {
+					root = document.createElement("div");
+					root.id = "autorefresh-runtime";
+					root.style.cssText = "position:fixed;right:8px;top:8px;z-index:2147483646;font:12px/1.4 monospace;color:#eee;text-align:right;";
+					document.body.appendChild(root);
+				}
+				root.replaceChildren();
+				const toggle = document.createElement("button");
+				toggle.textContent = "runtime";
+				toggle.style.cssText = "font:inherit;opacity:0.8;";
+				toggle.onclick = function () {
+					open = !open;
+					localStorage.setItem("autorefresh:runtime-open", open ? "1" : "0");
+					render();
+				};
+				root.appendChild(toggle);
+				if (!open) {
+					return;
+				}
+				const panel = document.createElement("div");
+				panel.style.cssText = "background:#222;padding:8px;margin-top:4px;border-radius:4px;text-align:left;";
+				const last = samples[samples.length - 1];
+				series.forEach(function (s) {
+					const row = document.createElement("div");
+					const label = document.createElement("div");
+					label.textContent = s.label + ": " + (last ? s.format(s.value(last)) : "-");
+					row.appendChild(label);
+					row.appendChild(sparkline(samples.map(s.value)));
+					panel.appendChild(row);
+				});
+				if (profilePath !== "") {
+					[["heap", "heap"], ["goroutine", "goroutines"], ["allocs", "allocs"], ["cpu", "cpu 10s"]].forEach(function (p) {
+						const link = document.createElement("a");
+						link.href = profilePath + "?profile=" + p[0];
+						link.download = "";
+						link.textContent = p[1];
+						link.style.cssText = "color:#7fd4ff;margin-right:8px;";
+						panel.appendChild(link);
+					});
+				}
+				root.appendChild(panel);
+			}
+
+			autorefresh.on("open", function () {
+				autorefresh.send("runtime", null);
+			});
+			autorefresh.on("runtime-state", function (state) {
+				profilePath = state.path;
+				history = state.history;
+				samples = limited(state.samples);
+				render();
+			});
+			autorefresh.on("runtime", function (sample) {
+				samples.push(sample);
+				samples = limited(samples);
+				if (open) {
+					render();
+				}
+			});
+			if (document.body) {
+				render();
+			} else {
+				document.addEventListener("DOMContentLoaded", render, { once: true });
+			}
+		})();
+`
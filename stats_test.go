@@ -0,0 +1,87 @@
+package autorefresh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestRuntimeStats(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	stats, err := autorefresh.NewRuntimeStats(reloader)
+	if err != nil {
+		t.Fatalf("Could not create runtime stats. %v", err)
+	}
+	stats.Interval = time.Millisecond * 20
+	stats.History = 120
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle(reloader.Path+"/", reloader)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go stats.Run(ctx)
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+reloader.Path, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	var m struct {
+		Type string                    `json:"type"`
+		Data autorefresh.RuntimeSample `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil {
+		t.Fatalf("No sample was streamed. %v", err)
+	}
+	if m.Type != "runtime" || m.Data.Goroutines == 0 || m.Data.HeapBytes == 0 {
+		t.Fatalf("Unexpected sample %s %+v", m.Type, m.Data)
+	}
+	if err := wsjson.Write(ctx, socket, autorefresh.Message{Type: "runtime"}); err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		Type string `json:"type"`
+		Data struct {
+			Path    string `json:"path"`
+			History int    `json:"history"`
+		} `json:"data"`
+	}
+	for state.Type != "runtime-state" {
+		if err := wsjson.Read(ctx, socket, &state); err != nil {
+			t.Fatalf("No state was sent. %v", err)
+		}
+	}
+	if state.Data.Path != reloader.Path+"/runtime" || state.Data.History != 120 {
+		t.Fatalf("Unexpected state %+v", state.Data)
+	}
+
+	resp, err := http.Get(server.URL + state.Data.Path + "?profile=goroutine")
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment") {
+		t.Fatalf("Profile was not served as a download. Got %d %v", resp.StatusCode, resp.Header)
+	}
+	resp, err = http.Get(server.URL + state.Data.Path + "?profile=unknown")
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected a 404 for an unknown profile, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,343 @@
+package autorefresh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Supervisor runs an application as a child process and restarts it whenever it exits. Exits that
+// happen sooner than MinUptime after starting count as crashes and repeated crashes are restarted with
+// an increasing delay. While the application is down, the exit status and the tail of its stderr
+// (or its panic trace, when there is one) are shown in the overlay of connected browsers.
+//
+// Browsers must be connected to a socket that outlives the application for this to be useful, e.g.
+// the one served by a Proxy in front of it.
+type Supervisor struct {
+	// Name is used to identify the application in reported problems.
+	Name string
+	// Command creates the command to run, it is called for every start. On unix, the command runs in
+	// a process group of its own, which is stopped as a whole, so processes it starts do not outlive it.
+	Command  func() *exec.Cmd
+	Reloader *PageReloader
+	// Handoff optionally passes a listening socket to the application. Restarts requested with
+	// Restart then start the new process and wait for it to call NotifyReady before stopping the old one.
+	Handoff *Handoff
+	Stdout  io.Writer
+	Stderr  io.Writer
+	// MinUptime is how long the application has to run for its exit not to count as a crash.
+	MinUptime time.Duration
+	// MinBackoff and MaxBackoff bound the delay before restarting after a crash.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ReadyTimeout is how long a process started by Restart has to become ready when using a Handoff.
+	ReadyTimeout time.Duration
+	// StopTimeout is how long the application is given to exit after being asked to stop before it is killed.
+	StopTimeout time.Duration
+	// TailSize is the number of bytes of stderr kept for crash reports.
+	TailSize int
+
+	restart chan struct{}
+}
+
+// NewSupervisor creates a Supervisor running name with args and reporting crashes to reloader,
+// which may be nil.
+func NewSupervisor(reloader *PageReloader, name string, args ...string) *Supervisor {
+	return &Supervisor{
+		Name:         name,
+		Command:      func() *exec.Cmd { return exec.Command(name, args...) },
+		Reloader:     reloader,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+		MinUptime:    time.Second * 5,
+		MinBackoff:   time.Millisecond * 500,
+		MaxBackoff:   time.Second * 30,
+		ReadyTimeout: time.Second * 30,
+		StopTimeout:  time.Second * 5,
+		TailSize:     32 << 10,
+		restart:      make(chan struct{}, 1),
+	}
+}
+
+// crashLoopThreshold is the number of consecutive crashes after which the application is reported
+// as crash looping.
+const crashLoopThreshold = 3
+
+type child struct {
+	cmd     *exec.Cmd
+	started time.Time
+	tail    *tailBuffer
+	ready   <-chan struct{}
+	exited  chan error
+	// settled is set once the child ran for MinUptime or became ready, or once a replacement of it
+	// failed, so the problem reported for the failure stays until the next successful start.
+	settled bool
+}
+
+// Restart asks the supervisor to restart the application, e.g. because its sources changed.
+func (s *Supervisor) Restart() {
+	select {
+	case s.restart <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the application and keeps it running until ctx is cancelled, at which point the
+// application is stopped.
+func (s *Supervisor) Run(ctx context.Context) error {
+	if s.Command == nil {
+		return fmt.Errorf("%w: Command is required", ErrInvalidParameters)
+	}
+	if s.restart == nil {
+		s.restart = make(chan struct{}, 1)
+	}
+	crashes := 0
+	for {
+		c, err := s.start()
+		if err != nil {
+			crashes++
+			s.report(fmt.Sprintf("%s could not be started: %v", s.Name, err), "", crashes, s.backoff(crashes))
+			if !s.sleep(ctx, s.backoff(crashes)) {
+				return nil
+			}
+			continue
+		}
+		for c != nil {
+			healthy := time.NewTimer(s.MinUptime - time.Since(c.started))
+			settle := healthy.C
+			if c.settled {
+				healthy.Stop()
+				settle = nil
+			}
+			select {
+			case <-ctx.Done():
+				healthy.Stop()
+				s.stop(c)
+				return nil
+			case <-settle:
+				c.settled = true
+				crashes = 0
+				s.clear()
+			case <-c.ready:
+				// A ready child is healthy no matter how long it has been running.
+				healthy.Stop()
+				c.ready = nil
+				c.settled = true
+				crashes = 0
+				s.clear()
+			case <-s.restart:
+				healthy.Stop()
+				c = s.replace(ctx, c)
+			case err := <-c.exited:
+				healthy.Stop()
+				uptime := time.Since(c.started)
+				if uptime < s.MinUptime {
+					crashes++
+				} else {
+					crashes = 0
+				}
+				delay := s.backoff(crashes)
+				s.report(fmt.Sprintf("%s exited: %v", s.Name, exitReason(err)), crashReport(c.tail.Bytes()), crashes, delay)
+				c = nil
+				if !s.sleep(ctx, delay) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (s *Supervisor) start() (*child, error) {
+	cmd := s.Command()
+	startGroup(cmd)
+	tail := &tailBuffer{max: s.TailSize}
+	cmd.Stdout = s.Stdout
+	cmd.Stderr = io.MultiWriter(s.Stderr, tail)
+	c := &child{cmd: cmd, tail: tail, exited: make(chan error, 1)}
+	if s.Handoff != nil {
+		ready, err := s.Handoff.Start(cmd)
+		if err != nil {
+			return nil, err
+		}
+		c.ready = ready
+	} else if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c.started = time.Now()
+	go func() { c.exited <- cmd.Wait() }()
+	return c, nil
+}
+
+// replace restarts the application. With a Handoff the new process is started first and the old one
+// is only stopped once the new one is ready, otherwise the old one is stopped first. When the new
+// process fails, the old one keeps serving and is settled, so the failure stays reported. A new
+// process that is not ready yet when ctx is cancelled is stopped, leaving the old one to Run.
+func (s *Supervisor) replace(ctx context.Context, old *child) *child {
+	if s.Handoff == nil {
+		s.stop(old)
+		c, err := s.start()
+		if err != nil {
+			s.report(fmt.Sprintf("%s could not be restarted: %v", s.Name, err), "", 1, 0)
+			return nil
+		}
+		return c
+	}
+	c, err := s.start()
+	if err != nil {
+		s.report(fmt.Sprintf("%s could not be restarted: %v", s.Name, err), "", 1, 0)
+		old.settled = true
+		return old
+	}
+	timer := time.NewTimer(s.ReadyTimeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		s.stop(c)
+		return old
+	case <-timer.C:
+		s.stop(c)
+		s.report(fmt.Sprintf("%s did not become ready within %s", s.Name, s.ReadyTimeout), crashReport(c.tail.Bytes()), 1, 0)
+		old.settled = true
+		return old
+	case <-c.ready:
+		c.ready = nil
+		c.settled = true
+		s.stop(old)
+		s.clear()
+		return c
+	case err := <-c.exited:
+		// Keep the old process serving while the new one is broken.
+		s.report(fmt.Sprintf("%s exited before becoming ready: %v", s.Name, exitReason(err)), crashReport(c.tail.Bytes()), 1, 0)
+		old.settled = true
+		return old
+	}
+}
+
+// stop asks the child and the processes it started to exit, and kills them if the child does not
+// exit within StopTimeout.
+func (s *Supervisor) stop(c *child) {
+	if err := signalGroup(c.cmd, syscall.SIGTERM); err != nil {
+		_ = signalGroup(c.cmd, syscall.SIGKILL)
+	}
+	timer := time.NewTimer(s.StopTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.exited:
+	case <-timer.C:
+		_ = signalGroup(c.cmd, syscall.SIGKILL)
+		<-c.exited
+	}
+}
+
+func (s *Supervisor) backoff(crashes int) time.Duration {
+	if crashes <= 1 {
+		return s.MinBackoff
+	}
+	d := s.MinBackoff
+	for i := 1; i < crashes && d < s.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.MaxBackoff {
+		d = s.MaxBackoff
+	}
+	return d
+}
+
+// sleep waits for d, returning early when a restart is requested and false when ctx is cancelled.
+func (s *Supervisor) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-s.restart:
+		return true
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *Supervisor) report(title, detail string, crashes int, delay time.Duration) {
+	fmt.Fprintf(s.Stderr, "%s\n", title)
+	if s.Reloader == nil {
+		return
+	}
+	if crashes >= crashLoopThreshold {
+		title = fmt.Sprintf("%s (crashed %d times in a row, restarting in %s)", title, crashes, delay)
+	} else if delay > 0 {
+		title = fmt.Sprintf("%s (restarting in %s)", title, delay)
+	}
+	s.Reloader.ReportProblem(Problem{Key: s.problemKey(), Title: title, Detail: detail})
+}
+
+func (s *Supervisor) clear() {
+	if s.Reloader != nil {
+		s.Reloader.ClearProblem(s.problemKey())
+	}
+}
+
+func (s *Supervisor) problemKey() string {
+	return "supervisor:" + s.Name
+}
+
+func exitReason(err error) string {
+	var exitErr *exec.ExitError
+	if err == nil {
+		return "exit status 0"
+	}
+	if errors.As(err, &exitErr) {
+		return exitErr.ProcessState.String()
+	}
+	return err.Error()
+}
+
+// crashReport returns the panic trace from the tail of stderr when there is one, and the whole
+// tail otherwise.
+func crashReport(tail []byte) string {
+	for i := 0; i < len(tail); {
+		line := tail[i:]
+		if bytes.HasPrefix(line, []byte("panic: ")) || bytes.HasPrefix(line, []byte("fatal error: ")) {
+			return string(line)
+		}
+		next := bytes.IndexByte(line, '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return string(tail)
+}
+
+// tailBuffer keeps the last max bytes written to it, starting at a line boundary when possible.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if over := len(t.buf) - t.max; over > 0 {
+		cut := over
+		if i := bytes.IndexByte(t.buf[over:], '\n'); i >= 0 {
+			cut = over + i + 1
+		}
+		t.buf = append(t.buf[:0], t.buf[cut:]...)
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.buf...)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package autorefresh
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func startGroup(cmd *exec.Cmd) {}
+
+// signalGroup signals the process of cmd only, process groups being a unix feature.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,228 @@
+//go:build unix
+
+package autorefresh_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestSupervisorReportsCrashLoop(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+
+	supervisor := autorefresh.NewSupervisor(reloader, "app")
+	supervisor.Command = func() *exec.Cmd {
+		return exec.Command("sh", "-c", "echo starting; echo 'panic: boom' >&2; echo 'goroutine 1 [running]:' >&2; exit 2")
+	}
+	supervisor.Stdout = io.Discard
+	supervisor.Stderr = io.Discard
+	supervisor.MinBackoff = time.Millisecond * 10
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	go func() { _ = supervisor.Run(ctx) }()
+
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/__reload", nil)
+	if err != nil {
+		t.Fatalf("Could not connect to reloader. %v", err)
+	}
+	defer socket.CloseNow()
+	for {
+		var m struct {
+			Type string              `json:"type"`
+			Data autorefresh.Problem `json:"data"`
+		}
+		if err := wsjson.Read(ctx, socket, &m); err != nil {
+			t.Fatalf("Crash loop was not reported. %v", err)
+		}
+		if m.Type != "problem" || !strings.Contains(m.Data.Title, "crashed 3 times in a row") {
+			continue
+		}
+		if !strings.HasPrefix(m.Data.Detail, "panic: boom\ngoroutine 1") {
+			t.Fatalf("Panic trace was not reported. Got %q", m.Data.Detail)
+		}
+		return
+	}
+}
+
+func TestSupervisorStopsProcessGroup(t *testing.T) {
+	t.Parallel()
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	supervisor := autorefresh.NewSupervisor(nil, "app")
+	supervisor.Command = func() *exec.Cmd {
+		// sh does not forward signals to the commands it runs, like "go run" does not.
+		return exec.Command("sh", "-c", "sleep 60 & echo $! > "+pidFile+"; wait")
+	}
+	supervisor.Stdout = io.Discard
+	supervisor.Stderr = io.Discard
+	supervisor.StopTimeout = time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = supervisor.Run(ctx)
+		close(done)
+	}()
+
+	var pid int
+	for deadline := time.Now().Add(time.Second * 5); pid == 0; time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal("Command did not start")
+		}
+		b, _ := os.ReadFile(pidFile)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatal("Supervisor did not stop the command")
+	}
+	for deadline := time.Now().Add(time.Second * 5); running(pid); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal("Process started by the command outlived it")
+		}
+	}
+}
+
+func TestSupervisorKeepsFailedRestartReported(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	handoff, err := autorefresh.NewHandoff(l)
+	if err != nil {
+		t.Fatalf("Could not create handoff. %v", err)
+	}
+	defer handoff.Close()
+
+	supervisor := autorefresh.NewSupervisor(reloader, "app")
+	starts := 0
+	supervisor.Command = func() *exec.Cmd {
+		starts++
+		if starts == 1 {
+			return exec.Command("sleep", "60")
+		}
+		return exec.Command("sh", "-c", "echo 'bad config' >&2; exit 1")
+	}
+	supervisor.Handoff = handoff
+	supervisor.Stdout = io.Discard
+	supervisor.Stderr = io.Discard
+	supervisor.MinUptime = time.Millisecond * 300
+	supervisor.StopTimeout = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_ = supervisor.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/__reload", nil)
+	if err != nil {
+		t.Fatalf("Could not connect to reloader. %v", err)
+	}
+	defer socket.CloseNow()
+	supervisor.Restart()
+	var m struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	for m.Type != "problem" {
+		if err := wsjson.Read(ctx, socket, &m); err != nil {
+			t.Fatalf("Failed restart was not reported. %v", err)
+		}
+	}
+	if !strings.Contains(string(m.Data), "exited before becoming ready") {
+		t.Fatalf("Unexpected problem %s", m.Data)
+	}
+
+	// The old process, still serving, reaches MinUptime.
+	readCtx, readCancel := context.WithTimeout(ctx, supervisor.MinUptime*3)
+	defer readCancel()
+	if err := wsjson.Read(readCtx, socket, &m); err == nil {
+		t.Fatalf("Failed restart stopped being reported: %s %s", m.Type, m.Data)
+	}
+}
+
+func TestSupervisorStopsWhileReplacing(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	handoff, err := autorefresh.NewHandoff(l)
+	if err != nil {
+		t.Fatalf("Could not create handoff. %v", err)
+	}
+	defer handoff.Close()
+
+	supervisor := autorefresh.NewSupervisor(nil, "sleep", "60")
+	starts := make(chan struct{}, 2)
+	command := supervisor.Command
+	supervisor.Command = func() *exec.Cmd {
+		starts <- struct{}{}
+		return command()
+	}
+	supervisor.Handoff = handoff
+	supervisor.Stdout = io.Discard
+	supervisor.Stderr = io.Discard
+	supervisor.StopTimeout = time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = supervisor.Run(ctx)
+		close(done)
+	}()
+	<-starts
+	supervisor.Restart()
+	<-starts
+	// The replacement never becomes ready, and ReadyTimeout is far longer than the test.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Supervisor waited for the replacement to become ready before stopping")
+	}
+}
+
+// running reports whether the process pid is alive, zombies waiting to be reaped do not count.
+func running(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	return err == nil && !strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
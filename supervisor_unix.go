@@ -0,0 +1,22 @@
+//go:build unix
+
+package autorefresh
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startGroup makes cmd start in a process group of its own, so stopping it also stops the processes
+// it started, such as the binary built by "go run" or the command run by "sh -c".
+func startGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends sig to the process group of cmd.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
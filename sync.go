@@ -0,0 +1,180 @@
+package autorefresh
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+)
+
+// relaySync forwards the interactions of a browser that knows the token to every other connected
+// browser, without the token.
+func (p *PageReloader) relaySync(_ context.Context, from *Client, m Message) {
+	if !p.Sync {
+		return
+	}
+	var event map[string]json.RawMessage
+	if err := m.Decode(&event); err != nil {
+		return
+	}
+	var token string
+	if err := json.Unmarshal(event["token"], &token); err != nil || !p.VerifyToken(token) {
+		return
+	}
+	delete(event, "token")
+	p.broadcast(Message{Type: "sync", Data: event}, from)
+}
+
+// syncScript mirrors interactions between browsers. Each browser picks a role with the toggle in
+// its bottom left corner, which is remembered per device: mirror sends and applies interactions,
+// leader only sends them, follower only applies them and off does neither. Scroll positions are
+// shared relative to the page size so they line up on different screen sizes, and only trusted
+// events are sent so applied interactions are not echoed back.
+const syncScript template.JS = `
+		(function () {
+			const roles = ["mirror", "leader", "follower", "off"];
+			let role = localStorage.getItem("autorefresh:sync-role") || "mirror";
+			let applyingUntil = 0;
+			function sends() {
+				return role === "mirror" || role === "leader";
+			}
+			function applies() {
+				return role === "mirror" || role === "follower";
+			}
+			function here() {
+				return window.location.pathname + window.location.search;
+			}
+			function selector(element) {
+				const parts = [];
+				for (let el = element; el && el.nodeType === 1 && el !== document.documentElement; el = el.parentElement) {
+					if (el.id) {
+						parts.unshift("#" + CSS.escape(el.id));
+						break;
+					}
+					let index = 1;
+					for (let sibling = el.previousElementSibling; sibling; sibling = sibling.previousElementSibling) {
+						if (sibling.tagName === el.tagName) {
+							index++;
+						}
+					}
+					parts.unshift(el.tagName.toLowerCase() + ":nth-of-type(" + index + ")");
+				}
+				return parts.join(" > ");
+			}
+			function share(kind, data) {
+				if (!sends() || Date.now() < applyingUntil) {
+					return;
+				}
+				data.kind = kind;
+				data.path = here();
+				data.token = config.token;
+				autorefresh.send("sync", data);
+			}
+			function scrollable() {
+				return {
+					width: document.documentElement.scrollWidth - window.innerWidth,
+					height: document.documentElement.scrollHeight - window.innerHeight,
+				};
+			}
+
+			let scrollTimer = null;
+			window.addEventListener("scroll", function () {
+				if (scrollTimer !== null) {
+					return;
+				}
+				scrollTimer = setTimeout(function () {
+					scrollTimer = null;
+					const size = scrollable();
+					share("scroll", {
+						x: size.width > 0 ? window.scrollX / size.width : 0,
+						y: size.height > 0 ? window.scrollY / size.height : 0,
+					});
+				}, 50);
+			}, { passive: true });
+			document.addEventListener("click", function (event) {
+				// Links are followed through the navigation that results from them.
+				if (!event.isTrusted || !(event.target instanceof Element) || event.target.closest("a[href], #autorefresh-sync")) {
+					return;
+				}
+				share("click", { selector: selector(event.target) });
+			}, true);
+			document.addEventListener("input", function (event) {
+				const target = event.target;
+				if (!event.isTrusted || !(target instanceof Element) || !("value" in target) || target.type === "password") {
+					return;
+				}
+				const checkable = target.type === "checkbox" || target.type === "radio";
+				share("input", { selector: selector(target), value: checkable ? target.checked : target.value });
+			}, true);
+			autorefresh.on("open", function () {
+				const navigation = performance.getEntriesByType("navigation")[0];
+				const synced = sessionStorage.getItem("autorefresh:sync-navigated") !== null;
+				sessionStorage.removeItem("autorefresh:sync-navigated");
+				if (!synced && !(navigation && navigation.type === "reload")) {
+					share("navigate", { href: window.location.href });
+				}
+			});
+
+			autorefresh.on("sync", function (event) {
+				if (!applies()) {
+					return;
+				}
+				if (event.kind === "navigate") {
+					let url = null;
+					try {
+						url = new URL(event.href, window.location.href);
+					} catch (e) {
+						return;
+					}
+					const web = url.protocol === "http:" || url.protocol === "https:";
+					if (web && url.origin === window.location.origin && url.href !== window.location.href) {
+						sessionStorage.setItem("autorefresh:sync-navigated", "1");
+						window.location.assign(url.href);
+					}
+					return;
+				}
+				if (event.path !== here()) {
+					return;
+				}
+				applyingUntil = Date.now() + 200;
+				if (event.kind === "scroll") {
+					const size = scrollable();
+					window.scrollTo(event.x * size.width, event.y * size.height);
+					return;
+				}
+				const target = document.querySelector(event.selector);
+				if (target === null) {
+					return;
+				}
+				if (event.kind === "click") {
+					target.click();
+				} else if (event.kind === "input") {
+					if (typeof event.value === "boolean") {
+						target.checked = event.value;
+					} else {
+						target.value = event.value;
+					}
+					target.dispatchEvent(new Event("input", { bubbles: true }));
+					target.dispatchEvent(new Event("change", { bubbles: true }));
+				}
+			});
+
+			function renderToggle() {
+				const toggle = document.createElement("button");
+				toggle.id = "autorefresh-sync";
+				toggle.title = "Synchronized browsing role of this device";
+				toggle.style.cssText = "position:fixed;left:8px;bottom:8px;z-index:2147483647;font:12px monospace;opacity:0.8;";
+				toggle.textContent = "sync: " + role;
+				toggle.onclick = function () {
+					role = roles[(roles.indexOf(role) + 1) % roles.length];
+					localStorage.setItem("autorefresh:sync-role", role);
+					toggle.textContent = "sync: " + role;
+				};
+				document.body.appendChild(toggle);
+			}
+			if (document.body) {
+				renderToggle();
+			} else {
+				document.addEventListener("DOMContentLoaded", renderToggle, { once: true });
+			}
+		})();
+`
@@ -0,0 +1,82 @@
+package autorefresh_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestSyncRelaysToOtherBrowsers(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	reloader.Sync = true
+	var script bytes.Buffer
+	if err := reloader.Template.Execute(&script, nil); err != nil {
+		t.Fatalf("Could not render template. %v", err)
+	}
+	if !strings.Contains(script.String(), `autorefresh.send("sync", data)`) {
+		t.Fatalf("Sync script was not included. Rendered %s", script.String())
+	}
+	token := regexp.MustCompile(`"token":"(\w+)"`).FindStringSubmatch(script.String())
+	if token == nil {
+		t.Fatalf("Script does not include the token. Rendered %s", script.String())
+	}
+
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	leader, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer leader.CloseNow()
+	follower, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer follower.CloseNow()
+	// Give the server a moment to register both sockets.
+	time.Sleep(time.Millisecond * 50)
+
+	forged := map[string]any{"kind": "navigate", "path": "/", "href": "javascript:alert(1)", "token": "forged"}
+	if err := wsjson.Write(ctx, leader, autorefresh.Message{Type: "sync", Data: forged}); err != nil {
+		t.Fatal(err)
+	}
+	event := map[string]any{"kind": "click", "path": "/", "selector": "#save", "token": token[1]}
+	if err := wsjson.Write(ctx, leader, autorefresh.Message{Type: "sync", Data: event}); err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := wsjson.Read(ctx, follower, &m); err != nil {
+		t.Fatalf("Follower did not receive the event. %v", err)
+	}
+	if m.Type != "sync" || !strings.Contains(string(m.Data), `"selector":"#save"`) {
+		t.Fatalf("Unexpected message %s %s", m.Type, m.Data)
+	}
+	if strings.Contains(string(m.Data), "token") {
+		t.Fatalf("Token was relayed to other browsers: %s", m.Data)
+	}
+
+	readCtx, readCancel := context.WithTimeout(ctx, time.Millisecond*100)
+	defer readCancel()
+	if err := wsjson.Read(readCtx, leader, &m); err == nil {
+		t.Fatalf("Event was echoed back to its sender: %s", m.Data)
+	}
+}
@@ -0,0 +1,410 @@
+package autorefresh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Toolbar shows a collapsible panel in the pages connected to a PageReloader with the timing of the
+// request that rendered the page, the templates it executed, the logs emitted while handling it, the
+// other recent requests and the recent reload events. Requests are recorded by its Middleware, and
+// the records are streamed to the browsers over the reloader socket as the requests finish.
+type Toolbar struct {
+	Reloader *PageReloader
+	// History is the number of requests and reload events kept for pages connecting later, and shown
+	// by the toolbar. Zero keeps them all.
+	History int
+	// InspectData keeps a snapshot of the data templates are executed with, see RecordTemplateData.
+	InspectData bool
+	// InspectLimits bound the snapshots of template data.
+	InspectLimits InspectLimits
+
+	mu       sync.Mutex
+	requests []RequestRecord
+	events   []ToolbarEvent
+}
+
+// RequestRecord describes a request handled by the Toolbar's Middleware.
+type RequestRecord struct {
+	ID        string           `json:"id"`
+	Method    string           `json:"method"`
+	Path      string           `json:"path"`
+	Status    int              `json:"status"`
+	Start     time.Time        `json:"start"`
+	Duration  time.Duration    `json:"duration"`
+	Templates []TemplateRecord `json:"templates"`
+	Logs      []LogRecord      `json:"logs"`
+}
+
+// TemplateRecord describes a template executed while handling a request.
+type TemplateRecord struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	// Data is a snapshot of the data the template was executed with, when it was recorded.
+	Data *InspectedValue `json:"data,omitempty"`
+}
+
+// LogRecord is a message logged while handling a request.
+type LogRecord struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// ToolbarEvent is a message broadcast to the browsers, such as a reload.
+type ToolbarEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Data any       `json:"data,omitempty"`
+}
+
+// toolbarState is sent to a browser when it connects, so it can show its own request.
+type toolbarState struct {
+	Requests []RequestRecord `json:"requests"`
+	Events   []ToolbarEvent  `json:"events"`
+	History  int             `json:"history"`
+}
+
+// requestRecorder collects the record of a request while it is being handled.
+type requestRecorder struct {
+	mu     sync.Mutex
+	record RequestRecord
+	// inspect holds the limits of data snapshots, nil when they are not taken.
+	inspect *InspectLimits
+}
+
+type requestRecorderKey struct{}
+
+// NewToolbar creates a Toolbar showing in the pages connected to reloader.
+func NewToolbar(reloader *PageReloader) *Toolbar {
+	t := &Toolbar{Reloader: reloader, History: 50, InspectData: true, InspectLimits: DefaultInspectLimits}
+	reloader.addScript(toolbarScript)
+	reloader.observe(t.recordEvent)
+	reloader.OnMessage("toolbar", func(_ context.Context, from *Client, _ Message) {
+		t.mu.Lock()
+		state := toolbarState{Requests: append([]RequestRecord{}, t.requests...), Events: append([]ToolbarEvent{}, t.events...), History: t.History}
+		t.mu.Unlock()
+		from.Send(Message{Type: "toolbar-state", Data: state})
+	})
+	return t
+}
+
+// Middleware records the requests handled by next, except the ones to the reloader. HTML responses
+// get the ID of their record, so the toolbar of the page shows the request that rendered it.
+func (t *Toolbar) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == t.Reloader.Path || strings.HasPrefix(r.URL.Path, t.Reloader.Path+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &requestRecorder{record: RequestRecord{
+			ID:     randomID(),
+			Method: r.Method,
+			Path:   r.URL.RequestURI(),
+			Start:  time.Now(),
+		}}
+		if t.InspectData {
+			limits := t.InspectLimits
+			rec.inspect = &limits
+		}
+		meta := []byte(fmt.Sprintf(`<meta name="autorefresh-request" content="%s">`, rec.record.ID))
+		iw := &injectWriter{ResponseWriter: w, script: func() []byte { return meta }}
+		next.ServeHTTP(iw, r.WithContext(context.WithValue(r.Context(), requestRecorderKey{}, rec)))
+		duration := time.Since(rec.record.Start)
+		iw.finish()
+
+		rec.mu.Lock()
+		rec.record.Duration = duration
+		rec.record.Status = iw.sent
+		if rec.record.Status == 0 && !iw.hijacked {
+			// net/http answers with 200 for handlers that write nothing.
+			rec.record.Status = http.StatusOK
+		}
+		record := rec.record
+		record.Templates = append([]TemplateRecord{}, rec.record.Templates...)
+		record.Logs = append([]LogRecord{}, rec.record.Logs...)
+		rec.mu.Unlock()
+		t.mu.Lock()
+		t.requests = appendLimited(t.requests, record, t.History)
+		t.mu.Unlock()
+		t.Reloader.Broadcast(Message{Type: "toolbar-request", Data: record})
+	})
+}
+
+// recordEvent keeps the reload events broadcast to the browsers.
+func (t *Toolbar) recordEvent(m Message) {
+	switch m.Type {
+	case "reload", "css", "problem", "problem-clear":
+	default:
+		return
+	}
+	event := ToolbarEvent{Time: time.Now(), Type: m.Type, Data: m.Data}
+	t.mu.Lock()
+	t.events = appendLimited(t.events, event, t.History)
+	t.mu.Unlock()
+	// Browsers that are about to reload also receive the event when they connect again.
+	t.Reloader.Broadcast(Message{Type: "toolbar-event", Data: event})
+}
+
+// RecordTemplate adds an executed template to the toolbar record of the request ctx belongs to. It
+// does nothing for requests that are not handled by a Toolbar's Middleware.
+func RecordTemplate(ctx context.Context, name string, duration time.Duration) {
+	if rec, ok := ctx.Value(requestRecorderKey{}).(*requestRecorder); ok {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.record.Templates = append(rec.record.Templates, TemplateRecord{Name: name, Duration: duration})
+	}
+}
+
+// RecordTemplateData is RecordTemplate, also keeping a snapshot of the data the template was executed
+// with when the Toolbar inspects data, so it can be browsed from the toolbar of the page. The
+// snapshot is taken right away, later changes to data are not reflected.
+func RecordTemplateData(ctx context.Context, name string, duration time.Duration, data any) {
+	if rec, ok := ctx.Value(requestRecorderKey{}).(*requestRecorder); ok {
+		record := TemplateRecord{Name: name, Duration: duration}
+		if rec.inspect != nil {
+			record.Data = Inspect(data, *rec.inspect)
+		}
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.record.Templates = append(rec.record.Templates, record)
+	}
+}
+
+// Logf adds a message to the toolbar record of the request ctx belongs to. It does nothing for
+// requests that are not handled by a Toolbar's Middleware. See ToolbarLogger for a *log.Logger.
+func Logf(ctx context.Context, format string, args ...any) {
+	if rec, ok := ctx.Value(requestRecorderKey{}).(*requestRecorder); ok {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.record.Logs = append(rec.record.Logs, LogRecord{Time: time.Now(), Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// ToolbarLogger returns a logger adding its messages to the toolbar record of the request ctx belongs
+// to, for code that logs to a *log.Logger. It discards the messages of requests that are not handled
+// by a Toolbar's Middleware. Only messages logged with Logf or this logger are recorded, the output of
+// the log package and of other loggers is not captured.
+func ToolbarLogger(ctx context.Context) *log.Logger {
+	rec, ok := ctx.Value(requestRecorderKey{}).(*requestRecorder)
+	if !ok {
+		return log.New(io.Discard, "", 0)
+	}
+	return log.New(logWriter{rec}, "", 0)
+}
+
+// logWriter adds each write of a log.Logger to the record as a message.
+type logWriter struct {
+	rec *requestRecorder
+}
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w.rec.mu.Lock()
+	defer w.rec.mu.Unlock()
+	w.rec.record.Logs = append(w.rec.record.Logs, LogRecord{Time: time.Now(), Message: strings.TrimSuffix(string(b), "\n")})
+	return len(b), nil
+}
+
+func appendLimited[T any](values []T, v T, limit int) []T {
+	values = append(values, v)
+	if limit > 0 && len(values) > limit {
+		values = append(values[:0:0], values[len(values)-limit:]...)
+	}
+	return values
+}
+
+// randomID returns a random hex string, used to identify requests and to authenticate browsers.
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// toolbarScript renders the toolbar. Its open state and tab are remembered per device, and it
+// shows the request whose ID the page was rendered with, once the socket delivers its record,
+// including the data its templates were executed with.
+const toolbarScript template.JS = `
+		(function () {
+			let requests = [];
+			let events = [];
+			let history = 0;
+			let open = localStorage.getItem("autorefresh:toolbar-open") === "1";
+			let tab = localStorage.getItem("autorefresh:toolbar-tab") || "request";
+			let root = null;
+
+			function el(tag, text, css) {
+				const e = document.createElement(tag);
+				if (text !== undefined && text !== null) {
+					e.textContent = text;
+				}
+				if (css) {
+					e.style.cssText = css;
+				}
+				return e;
+			}
+			function ms(duration) {
+				return (duration / 1e6).toFixed(1) + "ms";
+			}
+			function time(t) {
+				return new Date(t).toLocaleTimeString();
+			}
+			function table(rows) {
+				const t = el("table", null, "border-collapse:collapse;width:100%;");
+				rows.forEach(function (cells) {
+					const tr = el("tr", null, "border-top:1px solid #444;");
+					cells.forEach(function (cell) {
+						tr.appendChild(el("td", cell, "padding:2px 6px;vertical-align:top;white-space:pre-wrap;"));
+					});
+					t.appendChild(tr);
+				});
+				return t;
+			}
+			function current() {
+				// The ID is injected at the end of the head, possibly after this script.
+				const meta = document.querySelector("meta[name=autorefresh-request]");
+				return meta !== null && requests.find(function (r) {
+					return r.id === meta.content;
+				});
+			}
+			function summary(name, v) {
+				let text = name + " " + v.type;
+				if (v.value !== undefined) {
+					text += " = " + v.value;
+				}
+				if (v.len !== undefined) {
+					text += " (len " + v.len + ")";
+				}
+				if (v.truncated) {
+					text += " [" + v.truncated + " left out]";
+				}
+				if (v.cycle) {
+					text += " [cycle]";
+				}
+				return text;
+			}
+			// inspect shows a snapshot of template data as a tree, expanding values as they are opened.
+			function inspect(name, v) {
+				if (!v.fields) {
+					return el("div", summary(name, v), "padding-left:14px;white-space:pre-wrap;");
+				}
+				const details = el("details", null, "padding-left:14px;");
+				details.appendChild(el("summary", summary(name, v), "cursor:pointer;white-space:pre-wrap;margin-left:-14px;"));
+				details.addEventListener("toggle", function () {
+					if (details.open && details.childNodes.length === 1) {
+						v.fields.forEach(function (f) {
+							const child = inspect(f.name, f.value);
+							if (f.unexported) {
+								child.style.opacity = "0.6";
+							}
+							details.appendChild(child);
+						});
+					}
+				});
+				return details;
+			}
+			function requestPanel(r) {
+				const panel = el("div");
+				if (!r) {
+					panel.appendChild(el("p", "The request of this page was not recorded."));
+					return panel;
+				}
+				panel.appendChild(el("p", r.method + " " + r.path + " " + r.status + " in " + ms(r.duration)));
+				panel.appendChild(el("h4", "Templates", "margin:6px 0;"));
+				panel.appendChild(r.templates.length === 0 ? el("p", "none") : table(r.templates.map(function (t) {
+					return [t.name, ms(t.duration)];
+				})));
+				r.templates.forEach(function (t) {
+					if (t.data) {
+						panel.appendChild(inspect(t.name + " .", t.data));
+					}
+				});
+				panel.appendChild(el("h4", "Logs", "margin:6px 0;"));
+				panel.appendChild(r.logs.length === 0 ? el("p", "none") : table(r.logs.map(function (l) {
+					return [time(l.time), l.message];
+				})));
+				return panel;
+			}
+			function render() {
+				if (!document.body) {
+					return;
+				}
+				if (root === null) {
+					root = el("div", null, "position:fixed;right:8px;bottom:8px;z-index:2147483646;font:12px/1.4 monospace;color:#eee;text-align:right;");
+					root.id = "autorefresh-toolbar";
+					document.body.appendChild(root);
+				}
+				root.replaceChildren();
+				const r = current();
+				if (open) {
+					const panel = el("div", null, "width:min(640px,90vw);max-height:50vh;overflow:auto;background:#222;padding:8px;margin-bottom:4px;text-align:left;border-radius:4px;");
+					const tabs = el("div", null, "margin-bottom:6px;");
+					["request", "requests", "events"].forEach(function (name) {
+						const button = el("button", name, "font:inherit;margin-right:4px;" + (name === tab ? "font-weight:bold;" : ""));
+						button.onclick = function () {
+							tab = name;
+							localStorage.setItem("autorefresh:toolbar-tab", tab);
+							render();
+						};
+						tabs.appendChild(button);
+					});
+					panel.appendChild(tabs);
+					if (tab === "request") {
+						panel.appendChild(requestPanel(r));
+					} else if (tab === "requests") {
+						panel.appendChild(table(requests.slice().reverse().map(function (q) {
+							return [time(q.start), q.method, q.path, String(q.status), ms(q.duration)];
+						})));
+					} else {
+						panel.appendChild(events.length === 0 ? el("p", "none") : table(events.slice().reverse().map(function (e) {
+							return [time(e.time), e.type, e.data === undefined ? "" : JSON.stringify(e.data)];
+						})));
+					}
+					root.appendChild(panel);
+				}
+				const toggle = el("button", r ? r.status + " " + ms(r.duration) : "dev", "font:inherit;opacity:0.8;");
+				toggle.title = "Development toolbar";
+				toggle.onclick = function () {
+					open = !open;
+					localStorage.setItem("autorefresh:toolbar-open", open ? "1" : "0");
+					render();
+				};
+				root.appendChild(toggle);
+			}
+
+			autorefresh.on("open", function () {
+				autorefresh.send("toolbar", null);
+			});
+			function limited(values) {
+				return history > 0 ? values.slice(-history) : values;
+			}
+			autorefresh.on("toolbar-state", function (state) {
+				requests = state.requests;
+				events = state.events;
+				history = state.history;
+				render();
+			});
+			autorefresh.on("toolbar-request", function (r) {
+				requests.push(r);
+				requests = limited(requests);
+				render();
+			});
+			autorefresh.on("toolbar-event", function (e) {
+				events.push(e);
+				events = limited(events);
+				render();
+			});
+			if (document.body) {
+				render();
+			} else {
+				document.addEventListener("DOMContentLoaded", render, { once: true });
+			}
+		})();
+`
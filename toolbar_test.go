@@ -0,0 +1,112 @@
+package autorefresh_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestToolbarStreamsRequestRecords(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	toolbar := autorefresh.NewToolbar(reloader)
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle(reloader.Path+"/", toolbar.Middleware(reloader))
+	mux.Handle("/", toolbar.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			return
+		}
+		autorefresh.Logf(r.Context(), "loading %s", "users")
+		autorefresh.ToolbarLogger(r.Context()).Printf("loaded %d users", 2)
+		autorefresh.RecordTemplate(r.Context(), "users.html", time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "<html><head></head><body>users</body></html>")
+	})))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+reloader.Path, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	time.Sleep(time.Millisecond * 50)
+
+	resp, err := http.Get(server.URL + "/users")
+	if err != nil {
+		t.Fatalf("Request failed. %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var m struct {
+		Type string                    `json:"type"`
+		Data autorefresh.RequestRecord `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil {
+		t.Fatalf("Record was not streamed. %v", err)
+	}
+	record := m.Data
+	if m.Type != "toolbar-request" || record.Path != "/users" || record.Status != http.StatusCreated {
+		t.Fatalf("Unexpected message %s %+v", m.Type, record)
+	}
+	if !strings.Contains(string(body), `<meta name="autorefresh-request" content="`+record.ID+`">`) {
+		t.Fatalf("Page does not reference its record. Got %s", body)
+	}
+	if len(record.Templates) != 1 || record.Templates[0].Name != "users.html" || len(record.Logs) != 2 || record.Logs[0].Message != "loading users" || record.Logs[1].Message != "loaded 2 users" {
+		t.Fatalf("Unexpected templates and logs %+v", record)
+	}
+
+	for _, path := range []string{reloader.Path + "/missing", "/empty"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Request failed. %v", err)
+		}
+		resp.Body.Close()
+	}
+	if err := wsjson.Read(ctx, socket, &m); err != nil {
+		t.Fatalf("Record was not streamed. %v", err)
+	}
+	if m.Data.Path != "/empty" || m.Data.Status != http.StatusOK {
+		t.Fatalf("Unexpected record %+v", m.Data)
+	}
+
+	reloader.Reload()
+	if err := wsjson.Write(ctx, socket, autorefresh.Message{Type: "toolbar"}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var state struct {
+			Type string `json:"type"`
+			Data struct {
+				Requests []autorefresh.RequestRecord `json:"requests"`
+				Events   []autorefresh.ToolbarEvent  `json:"events"`
+				History  int                         `json:"history"`
+			} `json:"data"`
+		}
+		if err := wsjson.Read(ctx, socket, &state); err != nil {
+			t.Fatalf("State was not sent. %v", err)
+		}
+		if state.Type != "toolbar-state" {
+			continue
+		}
+		if len(state.Data.Requests) != 2 || len(state.Data.Events) != 1 || state.Data.Events[0].Type != "reload" || state.Data.History != 50 {
+			t.Fatalf("Unexpected state %+v", state.Data)
+		}
+		break
+	}
+}
@@ -0,0 +1,210 @@
+package autorefresh
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/lavigneer/browser-autorefresh/internal/htmltoken"
+)
+
+// HTMLIssue is a mistake found in an HTML document by CheckHTML.
+type HTMLIssue struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (i HTMLIssue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// maxReportedIssues is the number of issues listed in the problem reported for a page.
+const maxReportedIssues = 20
+
+// voidElements have no content and no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndTags are the elements whose end tag may be left out.
+var optionalEndTags = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true, "option": true,
+	"optgroup": true, "tr": true, "td": true, "th": true, "thead": true, "tbody": true, "tfoot": true,
+	"colgroup": true, "caption": true, "rp": true, "rt": true,
+}
+
+// closesParagraph are the elements that end an open paragraph when they start.
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "details": true, "div": true,
+	"dl": true, "fieldset": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// ValidateHTML is a middleware checking the HTML documents served by next with CheckHTML, and showing
+// the issues of each page as a warning in the overlay of the connected browsers until the page is
+// served without issues again. Responses are passed through unchanged.
+func (p *PageReloader) ValidateHTML(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iw := &injectWriter{ResponseWriter: w, script: func() []byte { return nil }}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+		if !iw.inject {
+			return
+		}
+		key := "html:" + r.URL.Path
+		issues := CheckHTML(iw.buf.Bytes())
+		if len(issues) == 0 {
+			p.ClearProblem(key)
+			return
+		}
+		lines := make([]string, 0, len(issues))
+		for i, issue := range issues {
+			if i == maxReportedIssues {
+				lines = append(lines, fmt.Sprintf("and %d more", len(issues)-i))
+				break
+			}
+			lines = append(lines, issue.String())
+		}
+		p.ReportProblem(Problem{
+			Key:    key,
+			Level:  "warning",
+			Title:  fmt.Sprintf("%d HTML issues in %s", len(issues), r.URL.Path),
+			Detail: strings.Join(lines, "\n"),
+		})
+	})
+}
+
+// openElement is an element on the stack of CheckHTML.
+type openElement struct {
+	name string
+	line int
+}
+
+// CheckHTML looks for common mistakes in an HTML document or fragment: elements that are not closed
+// or closed without being opened, duplicate IDs, images without an alt attribute, elements nested
+// where browsers do not allow them, and documents without a title. Issues are returned in the order
+// of the document.
+func CheckHTML(document []byte) []HTMLIssue {
+	var issues []HTMLIssue
+	report := func(line int, format string, args ...any) {
+		issues = append(issues, HTMLIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	var stack []openElement
+	inside := func(names ...string) bool {
+		for _, e := range stack {
+			for _, name := range names {
+				if e.name == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	ids := map[string]int{}
+	full, titled := false, false
+
+	tokens := htmltoken.Tokenize(document)
+	for i, t := range tokens {
+		switch t.Kind {
+		case htmltoken.Doctype:
+			full = true
+		case htmltoken.StartTag:
+			if id, ok := t.Attr("id"); ok {
+				if first, ok := ids[id]; ok {
+					report(t.Line, "duplicate id %q, first used on line %d", id, first)
+				} else {
+					ids[id] = t.Line
+				}
+			}
+			switch t.Name {
+			case "html":
+				full = true
+			case "title":
+				titled = titled || (i+1 < len(tokens) && tokens[i+1].Kind == htmltoken.Text && strings.TrimSpace(tokens[i+1].Data) != "")
+			case "img":
+				if _, ok := t.Attr("alt"); !ok {
+					src, _ := t.Attr("src")
+					report(t.Line, "<img src=%q> has no alt attribute", src)
+				}
+			case "a", "button":
+				if inside("a", "button") {
+					report(t.Line, "<%s> inside an interactive element", t.Name)
+				}
+			case "form":
+				if inside("form") {
+					report(t.Line, "<form> inside another form")
+				}
+			case "li":
+				if top := parent(stack, "li"); top != "ul" && top != "ol" && top != "menu" {
+					report(t.Line, "<li> outside of a list")
+				}
+			case "td", "th":
+				if top := parent(stack, "td", "th"); top != "tr" {
+					report(t.Line, "<%s> outside of a table row", t.Name)
+				}
+			}
+			if closesParagraph[t.Name] && len(stack) > 0 && stack[len(stack)-1].name == "p" {
+				if t.Name != "p" {
+					report(t.Line, "<%s> inside <p> opened on line %d, the paragraph ends before it", t.Name, stack[len(stack)-1].line)
+				}
+				stack = stack[:len(stack)-1]
+			}
+			foreign := inside("svg", "math")
+			if voidElements[t.Name] || (t.SelfClosing && foreign) {
+				continue
+			}
+			stack = append(stack, openElement{name: t.Name, line: t.Line})
+		case htmltoken.EndTag:
+			if voidElements[t.Name] {
+				continue
+			}
+			open := -1
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].name == t.Name {
+					open = j
+					break
+				}
+			}
+			if open < 0 {
+				if !optionalEndTags[t.Name] {
+					report(t.Line, "</%s> closes an element that is not open", t.Name)
+				}
+				continue
+			}
+			for _, e := range stack[open+1:] {
+				if !optionalEndTags[e.name] {
+					report(e.line, "<%s> is not closed before </%s> on line %d", e.name, t.Name, t.Line)
+				}
+			}
+			stack = stack[:open]
+		}
+	}
+	for _, e := range stack {
+		if !optionalEndTags[e.name] {
+			report(e.line, "<%s> is never closed", e.name)
+		}
+	}
+	if full && !titled {
+		report(1, "document has no <title>")
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// parent returns the name of the innermost open element, skipping the open elements named skip since
+// their end tags are optional and a new one closes them.
+func parent(stack []openElement, skip ...string) string {
+	for j := len(stack) - 1; j >= 0; j-- {
+		skipped := false
+		for _, name := range skip {
+			skipped = skipped || stack[j].name == name
+		}
+		if !skipped {
+			return stack[j].name
+		}
+	}
+	return ""
+}
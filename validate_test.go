@@ -0,0 +1,112 @@
+package autorefresh_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestCheckHTML(t *testing.T) {
+	t.Parallel()
+	document := `<!DOCTYPE html>
+<html>
+<head><title> </title></head>
+<body>
+<div id="main">
+<p>intro<div>block</div>
+<ul><li>one<li>two</ul>
+<li>stray</li>
+<a href="/"><button>go</button></a>
+<img src="/logo.png">
+<span id="main">
+<svg><path d="M0 0"/></svg>
+</section>
+</body>
+</html>`
+	var messages []string
+	for _, issue := range autorefresh.CheckHTML([]byte(document)) {
+		messages = append(messages, issue.String())
+	}
+	expected := []string{
+		"line 1: document has no <title>",
+		"line 5: <div> is not closed before </body> on line 14",
+		"line 6: <div> inside <p> opened on line 6, the paragraph ends before it",
+		"line 8: <li> outside of a list",
+		"line 9: <button> inside an interactive element",
+		`line 10: <img src="/logo.png"> has no alt attribute`,
+		`line 11: duplicate id "main", first used on line 5`,
+		"line 11: <span> is not closed before </body> on line 14",
+		"line 13: </section> closes an element that is not open",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Fatalf("Expected issues\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+
+	if issues := autorefresh.CheckHTML([]byte(`<table><tr><td>a<td>b</table><p>fragment<br><img src="x" alt="">`)); len(issues) != 0 {
+		t.Fatalf("Expected a valid fragment, got %v", issues)
+	}
+}
+
+func TestValidateHTML(t *testing.T) {
+	t.Parallel()
+	reloader, err := autorefresh.New(nil, "/__reload", 100)
+	if err != nil {
+		t.Fatalf("Could not create reloader. %v", err)
+	}
+	page := `<html><head><title>Page</title></head><body><img src="/logo.png"></body></html>`
+	mux := http.NewServeMux()
+	mux.Handle(reloader.Path, reloader)
+	mux.Handle("/", reloader.ValidateHTML(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, page)
+	})))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func() {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/page")
+		if err != nil {
+			t.Fatalf("Request failed. %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != page {
+			t.Fatalf("Response was changed. Got %s", body)
+		}
+	}
+	get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+reloader.Path, nil)
+	if err != nil {
+		t.Fatalf("Could not connect. %v", err)
+	}
+	defer socket.CloseNow()
+	var problem struct {
+		Type string              `json:"type"`
+		Data autorefresh.Problem `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &problem); err != nil || problem.Type != "problem" || problem.Data.Level != "warning" || !strings.Contains(problem.Data.Detail, "alt") {
+		t.Fatalf("Issues were not reported. Got %+v %v", problem, err)
+	}
+
+	page = strings.Replace(page, "<img", `<img alt="logo"`, 1)
+	get()
+	var clear struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+	if err := wsjson.Read(ctx, socket, &clear); err != nil || clear.Type != "problem-clear" || clear.Data != "html:/page" {
+		t.Fatalf("Issues were not cleared. Got %+v %v", clear, err)
+	}
+}
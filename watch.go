@@ -0,0 +1,111 @@
+package autorefresh
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher polls a directory tree for changed, added and removed files. Polling works the same on
+// every platform, and the trees watched during development are small enough for it to be cheap.
+type Watcher struct {
+	Root     string
+	Interval time.Duration
+	// Match reports whether a file, given by its slash-separated path relative to Root, is watched.
+	// All files are watched when it is nil. Hidden directories and node_modules are always skipped.
+	Match func(file string) bool
+}
+
+// NewWatcher creates a Watcher for every file below root.
+func NewWatcher(root string) *Watcher {
+	return &Watcher{Root: root, Interval: time.Millisecond * 500}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch calls changed with the paths relative to Root of the files that changed since the previous
+// poll, until ctx is cancelled.
+func (w *Watcher) Watch(ctx context.Context, changed func(paths []string)) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	previous := w.scan()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := w.scan()
+		var paths []string
+		for file, state := range current {
+			if old, ok := previous[file]; !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+				paths = append(paths, file)
+			}
+		}
+		for file := range previous {
+			if _, ok := current[file]; !ok {
+				paths = append(paths, file)
+			}
+		}
+		previous = current
+		if len(paths) > 0 {
+			sort.Strings(paths)
+			changed(paths)
+		}
+	}
+}
+
+func (w *Watcher) scan() map[string]fileState {
+	files := map[string]fileState{}
+	_ = filepath.WalkDir(w.Root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if file != w.Root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(w.Root, file)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if w.Match != nil && !w.Match(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[rel] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files
+}
+
+// MatchPatterns returns a Watcher.Match function accepting files matching any of patterns, as
+// understood by path.Match. Patterns without a slash are matched against the file name only.
+func MatchPatterns(patterns ...string) func(file string) bool {
+	return func(file string) bool {
+		for _, pattern := range patterns {
+			name := file
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(file)
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+package autorefresh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	autorefresh "github.com/lavigneer/browser-autorefresh"
+)
+
+func TestWatcherReportsChangedFiles(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "style.css", ".git/HEAD"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher := autorefresh.NewWatcher(root)
+	watcher.Interval = time.Millisecond * 20
+	watcher.Match = autorefresh.MatchPatterns("*.go", "*.css", "HEAD")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	changes := make(chan []string, 16)
+	go watcher.Watch(ctx, func(paths []string) { changes <- paths })
+
+	time.Sleep(time.Millisecond * 50)
+	_ = os.WriteFile(filepath.Join(root, "main.go"), []byte("changed"), 0o644)
+	_ = os.WriteFile(filepath.Join(root, ".git/HEAD"), []byte("changed"), 0o644)
+	_ = os.Remove(filepath.Join(root, "style.css"))
+
+	// The changes may be spread over several polls.
+	var changed []string
+	for len(changed) < 2 {
+		select {
+		case paths := <-changes:
+			changed = append(changed, paths...)
+		case <-ctx.Done():
+			t.Fatalf("Changes were not reported, got %v", changed)
+		}
+	}
+	if !reflect.DeepEqual(changed, []string{"main.go", "style.css"}) {
+		t.Fatalf("Unexpected changes %v", changed)
+	}
+}